@@ -5,8 +5,6 @@ import (
 	"io/fs"
 	"net/http"
 	"os"
-	"sort"
-	"time"
 
 	"github.com/app-nerds/nerdweb/v2/middlewares"
 	"github.com/gorilla/mux"
@@ -14,16 +12,25 @@ import (
 
 /*
 BasicWebAppConfig is used to configure a Go template web application router
+
+CORS controls the CORS policy. When nil, all origins, methods, and
+headers are allowed. ErrorMapper and Logger are used for endpoints
+with an ErrorHandlerFunc. Set VersionEndpoint to serve build
+information, including Version, as JSON.
 */
 type BasicWebAppConfig struct {
-	AppDirectory  string
-	AppFileSystem embed.FS
-	Endpoints     Endpoints
-	Host          string
-	IdleTimeout   int
-	ReadTimeout   int
-	Version       string
-	WriteTimeout  int
+	AppDirectory    string
+	AppFileSystem   embed.FS
+	CORS            *middlewares.CORSConfig
+	Endpoints       Endpoints
+	ErrorMapper     ErrorMapper
+	Host            string
+	IdleTimeout     int
+	Logger          Logger
+	ReadTimeout     int
+	Version         string
+	VersionEndpoint *VersionOptions
+	WriteTimeout    int
 }
 
 /*
@@ -51,30 +58,7 @@ some preconfigured defaults for basic web applications. The HTTP server
 is setup to use the resulting router.
 */
 func NewBasicWebAppRouterAndServer(config BasicWebAppConfig) (*mux.Router, *http.Server) {
-	router := mux.NewRouter()
-	server := &http.Server{
-		Addr:         config.Host,
-		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
-		Handler:      router,
-	}
-
-	fs := http.FileServer(getBasicWebAppFileSystem(config))
-	router.Use(middlewares.AccessControl(middlewares.AllowAllOrigins, middlewares.AllowAllMethods, middlewares.AllowAllHeaders))
-
-	sort.Sort(config.Endpoints)
-
-	for _, e := range config.Endpoints {
-		if e.HandlerFunc != nil {
-			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
-		} else {
-			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
-		}
-	}
-
-	router.PathPrefix("/static/").Handler(fs).Methods(http.MethodGet)
-	return router, server
+	return NewServer(basicWebAppOptions(config)...)
 }
 
 /*
@@ -83,29 +67,35 @@ with some preconfigured defaults for a basic web application. The HTTP
 server is setup to use the resulting router.
 */
 func NewBasicWebAppServer(router *mux.Router, config BasicWebAppConfig) *http.Server {
-	server := &http.Server{
-		Addr:         config.Host,
-		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
-		Handler:      router,
+	_, server := NewServer(append(basicWebAppOptions(config), WithRouter(router))...)
+	return server
+}
+
+func basicWebAppOptions(config BasicWebAppConfig) []Option {
+	opts := []Option{
+		WithHost(config.Host),
+		WithTimeouts(config.IdleTimeout, config.ReadTimeout, config.WriteTimeout),
+		WithEndpoints(config.Endpoints),
+		WithErrorMapper(config.ErrorMapper),
+		WithLogger(config.Logger),
+		WithStaticDir("/static/", getBasicWebAppFileSystem(config)),
 	}
 
-	fs := http.FileServer(getBasicWebAppFileSystem(config))
-	router.Use(middlewares.AccessControl(middlewares.AllowAllOrigins, middlewares.AllowAllMethods, middlewares.AllowAllHeaders))
+	if config.CORS != nil {
+		opts = append(opts, WithCORS(*config.CORS))
+	}
 
-	sort.Sort(config.Endpoints)
+	if config.VersionEndpoint != nil {
+		versionOptions := *config.VersionEndpoint
 
-	for _, e := range config.Endpoints {
-		if e.HandlerFunc != nil {
-			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
-		} else {
-			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
+		if versionOptions.Version == "" {
+			versionOptions.Version = config.Version
 		}
+
+		opts = append(opts, WithVersion(versionOptions))
 	}
 
-	router.PathPrefix("/static/").Handler(fs).Methods(http.MethodGet)
-	return server
+	return opts
 }
 
 func getBasicWebAppFileSystem(config BasicWebAppConfig) http.FileSystem {
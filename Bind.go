@@ -0,0 +1,380 @@
+package nerdweb
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultMaxFormMemory = 32 << 20
+
+var ErrInvalidParameters = errors.New("request has invalid parameters")
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+var bindSources = []struct {
+	label string
+	tag   string
+}{
+	{label: "path parameter", tag: "path"},
+	{label: "query parameter", tag: "query"},
+	{label: "header", tag: "header"},
+	{label: "form field", tag: "form"},
+	{label: "cookie", tag: "cookie"},
+}
+
+/*
+Bind fills the struct dest points to from the request, using these
+field tags:
+
+  - path: a route variable, such as {id}
+  - query: a query string parameter
+  - header: a request header
+  - form: a url-encoded or multipart form field
+  - cookie: a cookie value
+
+When a field has more than one of these tags, the first one found is
+used, in the order above. A `default` tag gives the value to use when
+none are found. Fields can be strings, bools, integers, floats,
+time.Duration, time.Time, or any type implementing
+encoding.TextUnmarshaler. Times are parsed using the `layout` tag,
+or RFC 3339 when there is none. Slices take every value of a repeated
+parameter or header, and a comma separated default. Pointer fields
+are left nil when the value is missing, which makes them optional.
+Untagged struct fields are bound recursively.
+
+Values that cannot be converted are all reported at once, as an
+*HTTPError with status 400, the code "invalid_parameters", and a
+FieldError for each value as details. It wraps ErrInvalidParameters.
+Bind does not check for missing values; use Validate for that.
+
+Example:
+
+  type ListWidgetsRequest struct {
+    Since    *time.Time `query:"since" layout:"2006-01-02"`
+    Page     int        `query:"page" default:"1"`
+    Status   []string   `query:"status"`
+    TenantID string     `header:"X-Tenant" validate:"required"`
+    UserID   int        `path:"userID"`
+  }
+
+  func listWidgets(w http.ResponseWriter, r *http.Request) error {
+    request := ListWidgetsRequest{}
+
+    if err := nerdweb.Bind(r, &request); err != nil {
+      return err
+    }
+
+    ...
+  }
+*/
+func Bind(r *http.Request, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Bind needs a pointer to a struct, got %T", dest)
+	}
+
+	binder := &requestBinder{request: r}
+
+	if err := binder.bindStruct(v.Elem()); err != nil {
+		return err
+	}
+
+	if len(binder.errors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(binder.errors))
+
+	for _, fieldError := range binder.errors {
+		messages = append(messages, fieldError.Message)
+	}
+
+	result := NewHTTPError(http.StatusBadRequest, "invalid_parameters", "the request has invalid parameters")
+	result.Details = binder.errors
+	result.Err = fmt.Errorf("%w: %s", ErrInvalidParameters, strings.Join(messages, "; "))
+	return result
+}
+
+type requestBinder struct {
+	errors     []FieldError
+	formParsed bool
+	query      url.Values
+	request    *http.Request
+}
+
+func (b *requestBinder) bindStruct(v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		values, source, name, tagged, err := b.lookup(field)
+
+		if err != nil {
+			return err
+		}
+
+		if !tagged {
+			if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+				if err = b.bindStruct(v.Field(i)); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if len(values) == 0 {
+			defaultValue, ok := field.Tag.Lookup("default")
+
+			if !ok {
+				continue
+			}
+
+			values = []string{defaultValue}
+
+			if isSliceField(field.Type) {
+				values = strings.Split(defaultValue, ",")
+			}
+		}
+
+		layout := field.Tag.Get("layout")
+
+		if layout == "" {
+			layout = time.RFC3339
+		}
+
+		if err = setBindValue(v.Field(i), values, layout); err != nil {
+			var conversionError *bindConversionError
+
+			if !errors.As(err, &conversionError) {
+				return fmt.Errorf("cannot bind field %s.%s: %w", t.Name(), field.Name, err)
+			}
+
+			b.errors = append(b.errors, FieldError{
+				Field:   name,
+				Message: fmt.Sprintf("%s %q must be %s", source, name, conversionError.expected),
+				Param:   conversionError.kind,
+				Rule:    "type",
+			})
+		}
+	}
+
+	return nil
+}
+
+func (b *requestBinder) lookup(field reflect.StructField) (values []string, source, name string, tagged bool, err error) {
+	for _, candidate := range bindSources {
+		candidateName := field.Tag.Get(candidate.tag)
+
+		if candidateName == "" || candidateName == "-" {
+			continue
+		}
+
+		if !tagged {
+			tagged, source, name = true, candidate.label, candidateName
+		}
+
+		if values, err = b.values(candidate.tag, candidateName); err != nil || len(values) > 0 {
+			return values, candidate.label, candidateName, true, err
+		}
+	}
+
+	return nil, source, name, tagged, nil
+}
+
+func (b *requestBinder) values(source, name string) ([]string, error) {
+	switch source {
+	case "path":
+		if value, ok := mux.Vars(b.request)[name]; ok {
+			return []string{value}, nil
+		}
+
+	case "query":
+		if b.query == nil {
+			b.query = b.request.URL.Query()
+		}
+
+		return b.query[name], nil
+
+	case "header":
+		return b.request.Header.Values(name), nil
+
+	case "form":
+		if err := b.parseForm(); err != nil {
+			return nil, err
+		}
+
+		return b.request.PostForm[name], nil
+
+	case "cookie":
+		if cookie, err := b.request.Cookie(name); err == nil {
+			return []string{cookie.Value}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *requestBinder) parseForm() error {
+	if b.formParsed {
+		return nil
+	}
+
+	b.formParsed = true
+	mediaType, _, _ := mime.ParseMediaType(b.request.Header.Get("Content-Type"))
+
+	var err error
+
+	if mediaType == "multipart/form-data" {
+		err = b.request.ParseMultipartForm(defaultMaxFormMemory)
+	} else {
+		err = b.request.ParseForm()
+	}
+
+	if err != nil {
+		result := NewHTTPError(http.StatusBadRequest, "invalid_form", "the request form could not be read")
+		result.Err = fmt.Errorf("%w: %w", ErrInvalidParameters, err)
+		return result
+	}
+
+	return nil
+}
+
+type bindConversionError struct {
+	expected string
+	kind     string
+}
+
+func (e *bindConversionError) Error() string {
+	return "value must be " + e.expected
+}
+
+func setBindValue(v reflect.Value, values []string, layout string) error {
+	switch {
+	case v.Kind() == reflect.Ptr:
+		value := reflect.New(v.Type().Elem())
+
+		if err := setBindValue(value.Elem(), values, layout); err != nil {
+			return err
+		}
+
+		v.Set(value)
+		return nil
+
+	case isSliceField(v.Type()):
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+
+		for index, value := range values {
+			if err := setBindValue(slice.Index(index), []string{value}, layout); err != nil {
+				return err
+			}
+		}
+
+		v.Set(slice)
+		return nil
+	}
+
+	return convertBindValue(v, values[0], layout)
+}
+
+func convertBindValue(v reflect.Value, value string, layout string) error {
+	if v.Type() == timeType {
+		parsed, err := time.Parse(layout, value)
+
+		if err != nil {
+			return &bindConversionError{expected: "a time in the format " + layout, kind: "time"}
+		}
+
+		v.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	if v.Type() == durationType {
+		parsed, err := time.ParseDuration(value)
+
+		if err != nil {
+			return &bindConversionError{expected: "a duration, such as 1m30s", kind: "duration"}
+		}
+
+		v.SetInt(int64(parsed))
+		return nil
+	}
+
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return &bindConversionError{expected: "a valid " + v.Type().Name(), kind: v.Type().Name()}
+		}
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return &bindConversionError{expected: "true or false", kind: "bool"}
+		}
+
+		v.SetBool(parsed)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, v.Type().Bits())
+
+		if err != nil {
+			return &bindConversionError{expected: "an integer", kind: "int"}
+		}
+
+		v.SetInt(parsed)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, v.Type().Bits())
+
+		if err != nil {
+			return &bindConversionError{expected: "a positive integer", kind: "uint"}
+		}
+
+		v.SetUint(parsed)
+
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, v.Type().Bits())
+
+		if err != nil {
+			return &bindConversionError{expected: "a number", kind: "float"}
+		}
+
+		v.SetFloat(parsed)
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func isSliceField(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && !t.Implements(textUnmarshalerType) && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
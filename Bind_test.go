@@ -0,0 +1,211 @@
+package nerdweb_test
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2"
+	"github.com/gorilla/mux"
+)
+
+type bindPaging struct {
+	Page     int `query:"page" default:"1"`
+	PageSize int `query:"pageSize" default:"25"`
+}
+
+type bindRequest struct {
+	bindPaging
+
+	Active   *bool         `query:"active"`
+	Name     string        `form:"name"`
+	Since    time.Time     `query:"since" layout:"2006-01-02"`
+	Session  string        `cookie:"sid"`
+	Status   []string      `query:"status" default:"open,pending"`
+	TenantID string        `header:"X-Tenant"`
+	Timeout  time.Duration `query:"timeout"`
+	Token    string        `query:"token" header:"X-Token"`
+	UserID   int64         `path:"userID"`
+	Weight   float64       `query:"weight"`
+}
+
+func bindRoute(t *testing.T, r *http.Request) (bindRequest, error) {
+	t.Helper()
+
+	var (
+		got    bindRequest
+		gotErr error
+	)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		gotErr = nerdweb.Bind(r, &got)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	return got, gotErr
+}
+
+func TestBind(t *testing.T) {
+	active := true
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    bindRequest
+	}{
+		{
+			name: "Binds from every source",
+			request: func() *http.Request {
+				query := "active=true&page=3&since=2024-02-01&status=closed&status=open&timeout=1m30s&weight=2.5"
+				r := httptest.NewRequest(http.MethodPost, "/users/42?"+query, strings.NewReader("name=Adam"))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				r.Header.Set("X-Tenant", "acme")
+				r.Header.Set("X-Token", "secret")
+				r.AddCookie(&http.Cookie{Name: "sid", Value: "abc"})
+				return r
+			},
+			want: bindRequest{
+				bindPaging: bindPaging{Page: 3, PageSize: 25},
+				Active:     &active,
+				Name:       "Adam",
+				Since:      time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				Session:    "abc",
+				Status:     []string{"closed", "open"},
+				TenantID:   "acme",
+				Timeout:    90 * time.Second,
+				Token:      "secret",
+				UserID:     42,
+				Weight:     2.5,
+			},
+		},
+		{
+			name: "Uses defaults and leaves optional values nil",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			},
+			want: bindRequest{
+				bindPaging: bindPaging{Page: 1, PageSize: 25},
+				Status:     []string{"open", "pending"},
+				UserID:     7,
+			},
+		},
+		{
+			name: "Prefers the first tag with a value",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/users/7?token=fromQuery", nil)
+				r.Header.Set("X-Token", "fromHeader")
+				return r
+			},
+			want: bindRequest{
+				bindPaging: bindPaging{Page: 1, PageSize: 25},
+				Status:     []string{"open", "pending"},
+				Token:      "fromQuery",
+				UserID:     7,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bindRoute(t, tt.request())
+
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBindMultipartForm(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("name", "Adam")
+	_ = writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/users/1", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	got, err := bindRoute(t, r)
+
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+
+	if got.Name != "Adam" {
+		t.Errorf("wanted name Adam, got %q", got.Name)
+	}
+}
+
+func TestBindConversionErrors(t *testing.T) {
+	query := url.Values{
+		"active":  {"maybe"},
+		"page":    {"two"},
+		"since":   {"yesterday"},
+		"timeout": {"soon"},
+	}
+
+	_, err := bindRoute(t, httptest.NewRequest(http.MethodGet, "/users/abc?"+query.Encode(), nil))
+
+	if !errors.Is(err, nerdweb.ErrInvalidParameters) {
+		t.Fatalf("wanted ErrInvalidParameters, got %v", err)
+	}
+
+	var httpError *nerdweb.HTTPError
+
+	if !errors.As(err, &httpError) {
+		t.Fatalf("wanted *nerdweb.HTTPError, got %T", err)
+	}
+
+	if httpError.Status != http.StatusBadRequest || httpError.Code != "invalid_parameters" {
+		t.Errorf("wanted 400 invalid_parameters, got %d %s", httpError.Status, httpError.Code)
+	}
+
+	want := []nerdweb.FieldError{
+		{Field: "page", Message: `query parameter "page" must be an integer`, Param: "int", Rule: "type"},
+		{Field: "active", Message: `query parameter "active" must be true or false`, Param: "bool", Rule: "type"},
+		{Field: "since", Message: `query parameter "since" must be a time in the format 2006-01-02`, Param: "time", Rule: "type"},
+		{Field: "timeout", Message: `query parameter "timeout" must be a duration, such as 1m30s`, Param: "duration", Rule: "type"},
+		{Field: "userID", Message: `path parameter "userID" must be an integer`, Param: "int", Rule: "type"},
+	}
+
+	if !reflect.DeepEqual(httpError.Details, want) {
+		t.Errorf("wanted %#v, got %#v", want, httpError.Details)
+	}
+}
+
+func TestBindRejectsInvalidDestinations(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?value=1", nil)
+
+	tests := []struct {
+		name string
+		dest interface{}
+	}{
+		{name: "Not a pointer", dest: bindRequest{}},
+		{name: "Not a struct", dest: new(string)},
+		{name: "Unsupported field type", dest: &struct {
+			Value map[string]string `query:"value"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := nerdweb.Bind(r, tt.dest)
+
+			if err == nil || errors.Is(err, nerdweb.ErrInvalidParameters) {
+				t.Errorf("wanted a destination error, got %v", err)
+			}
+		})
+	}
+}
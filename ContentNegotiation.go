@@ -0,0 +1,401 @@
+package nerdweb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ErrEncodingNotSupported is returned by an Encoder that cannot encode
+a value, such as the CSV encoder given something other than a slice
+of structs. Write then tries the next acceptable encoder.
+*/
+var ErrEncodingNotSupported = errors.New("value cannot be encoded in this format")
+
+/*
+Encoder writes value to w in one format.
+*/
+type Encoder func(w io.Writer, value interface{}) error
+
+type registeredEncoder struct {
+	contentType string
+	encoder     Encoder
+	mediaType   string
+}
+
+var (
+	encoders     []registeredEncoder
+	encodersLock sync.RWMutex
+)
+
+func init() {
+	RegisterEncoder("application/json", EncodeJSON)
+	RegisterEncoder("application/xml; charset=utf-8", EncodeXML)
+	RegisterEncoder("text/csv; charset=utf-8", EncodeCSV)
+}
+
+/*
+RegisterEncoder adds an encoder for a content type, which is sent as
+the Content-Type header and may include parameters such as charset.
+Registering a media type again replaces its encoder. When the client
+accepts several formats equally, the one registered first is used.
+JSON, XML, and CSV are registered, in that order.
+
+Example:
+
+  nerdweb.RegisterEncoder("application/yaml", func(w io.Writer, value interface{}) error {
+    return yaml.NewEncoder(w).Encode(value)
+  })
+
+  nerdweb.RegisterEncoder("application/msgpack", func(w io.Writer, value interface{}) error {
+    return msgpack.NewEncoder(w).Encode(value)
+  })
+*/
+func RegisterEncoder(contentType string, encoder Encoder) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		panic(fmt.Sprintf("nerdweb: invalid content type %q: %v", contentType, err))
+	}
+
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+
+	registered := registeredEncoder{contentType: contentType, encoder: encoder, mediaType: mediaType}
+
+	for index := range encoders {
+		if encoders[index].mediaType == mediaType {
+			encoders[index] = registered
+			return
+		}
+	}
+
+	encoders = append(encoders, registered)
+}
+
+/*
+Write writes value in the format that best matches the request's
+Accept header, honoring q-values. When there is no Accept header the
+first registered encoder, JSON, is used. When nothing registered is
+acceptable a 406 Not Acceptable problem is written, listing the
+available types. The Vary header always includes Accept. If encoding
+fails a problem response is written instead, and the error is logged
+with the request-scoped logger.
+
+Example:
+
+  func listWidgets(w http.ResponseWriter, r *http.Request) {
+    widgets := getWidgets()
+    nerdweb.Write(w, r, http.StatusOK, widgets)
+  }
+*/
+func Write(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	addVary(w.Header(), "Accept")
+
+	candidates := acceptableEncoders(r.Header.Get("Accept"))
+	b := &bytes.Buffer{}
+
+	for _, candidate := range candidates {
+		b.Reset()
+		err := candidate.encoder(b, value)
+
+		if errors.Is(err, ErrEncodingNotSupported) {
+			continue
+		}
+
+		if err != nil {
+			logger := resolveLogger(nil, w)
+			logger.WithError(err).WithFields(LogFields{"contentType": candidate.contentType}).Error("error encoding value for writing")
+			WriteProblem(logger, w, NewProblem(http.StatusInternalServerError, "Error encoding value for writing. See error log for more information"))
+			return
+		}
+
+		w.Header().Set("Content-Type", candidate.contentType)
+		w.WriteHeader(status)
+		_, _ = w.Write(b.Bytes())
+		return
+	}
+
+	problem := NewProblem(http.StatusNotAcceptable, "none of the requested media types are available")
+	problem.Extensions = map[string]interface{}{"available": availableMediaTypes()}
+	WriteProblem(nil, w, problem)
+}
+
+/*
+EncodeJSON encodes value as JSON.
+*/
+func EncodeJSON(w io.Writer, value interface{}) error {
+	b, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+/*
+EncodeXML encodes value as an XML document. Slices and arrays, which
+would have no single root element, and values encoding/xml cannot
+encode, such as maps, return ErrEncodingNotSupported.
+*/
+func EncodeXML(w io.Writer, value interface{}) error {
+	v := reflect.ValueOf(value)
+
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return fmt.Errorf("%w: XML needs a single root element, got %T", ErrEncodingNotSupported, value)
+	}
+
+	b, err := xml.Marshal(value)
+
+	if err != nil {
+		var unsupported *xml.UnsupportedTypeError
+
+		if errors.As(err, &unsupported) {
+			return fmt.Errorf("%w: %w", ErrEncodingNotSupported, err)
+		}
+
+		return err
+	}
+
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+/*
+EncodeCSV encodes a slice of structs, or pointers to structs, as CSV
+with a header row. Columns are named by the `csv` struct tag, then
+the `json` tag, then the field name; a name of "-" leaves the field
+out. Times are written in RFC 3339 format and nil pointers as empty
+cells. Other values return ErrEncodingNotSupported.
+*/
+func EncodeCSV(w io.Writer, value interface{}) error {
+	v := reflect.ValueOf(value)
+
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("%w: CSV needs a slice of structs, got %T", ErrEncodingNotSupported, value)
+	}
+
+	elementType := v.Type().Elem()
+
+	for elementType.Kind() == reflect.Ptr {
+		elementType = elementType.Elem()
+	}
+
+	if elementType.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: CSV needs a slice of structs, got %T", ErrEncodingNotSupported, value)
+	}
+
+	columns, indexes := csvColumns(elementType)
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(indexes))
+
+	for i := 0; i < v.Len(); i++ {
+		element := indirect(v.Index(i))
+
+		for column, index := range indexes {
+			record[column] = ""
+
+			if element.IsValid() {
+				record[column] = csvCell(element.Field(index))
+			}
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvColumns(t reflect.Type) ([]string, []int) {
+	columns := []string{}
+	indexes := []int{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("csv"), ",")
+
+		if name == "" {
+			name = fieldName(field)
+		}
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		columns = append(columns, name)
+		indexes = append(indexes, i)
+	}
+
+	return columns, indexes
+}
+
+func csvCell(v reflect.Value) string {
+	v = indirect(v)
+
+	if !v.IsValid() {
+		return ""
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+/*
+acceptableEncoders returns the registered encoders the client accepts,
+best first. For each encoder the most specific matching media range
+decides its quality.
+*/
+func acceptableEncoders(accept string) []registeredEncoder {
+	encodersLock.RLock()
+	registered := append([]registeredEncoder{}, encoders...)
+	encodersLock.RUnlock()
+
+	ranges := parseAccept(accept)
+
+	if len(ranges) == 0 {
+		return registered
+	}
+
+	qualities := map[string]float64{}
+	result := []registeredEncoder{}
+
+	for _, encoder := range registered {
+		quality, specificity := 0.0, -1
+
+		for _, accepted := range ranges {
+			if s := matchMediaRange(accepted.mediaType, encoder.mediaType); s > specificity {
+				quality, specificity = accepted.quality, s
+			}
+		}
+
+		if quality > 0 {
+			qualities[encoder.mediaType] = quality
+			result = append(result, encoder)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return qualities[result[i].mediaType] > qualities[result[j].mediaType]
+	})
+
+	return result
+}
+
+func parseAccept(accept string) []mediaRange {
+	result := []mediaRange{}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		result = append(result, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	return result
+}
+
+/*
+matchMediaRange returns how specifically a media range matches a
+media type: 2 for an exact match, 1 for a subtype wildcard such as
+"text/*", 0 for the full wildcard, and -1 when it does not match.
+*/
+func matchMediaRange(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
+
+func availableMediaTypes() []string {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+
+	result := make([]string, 0, len(encoders))
+
+	for _, encoder := range encoders {
+		result = append(result, encoder.mediaType)
+	}
+
+	return result
+}
+
+func addVary(header http.Header, value string) {
+	for _, existing := range header.Values("Vary") {
+		for _, field := range strings.Split(existing, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", value)
+}
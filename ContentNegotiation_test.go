@@ -0,0 +1,201 @@
+package nerdweb_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+type negotiationWidget struct {
+	Created time.Time `json:"created" xml:"created"`
+	ID      int       `json:"id" xml:"id"`
+	Name    string    `json:"name" xml:"name" csv:"widget_name"`
+	Owner   *string   `json:"owner" xml:"owner"`
+	Secret  string    `json:"-" xml:"-"`
+}
+
+func TestWrite(t *testing.T) {
+	owner := "Adam"
+	created := time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	widgets := []negotiationWidget{
+		{Created: created, ID: 1, Name: "Sprocket", Owner: &owner, Secret: "hidden"},
+		{Created: created, ID: 2, Name: "Gear, large"},
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		value           interface{}
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Uses JSON when there is no Accept header",
+			value:           widgets[:1],
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `[{"created":"2024-02-01T12:00:00Z","id":1,"name":"Sprocket","owner":"Adam"}]`,
+		},
+		{
+			name:            "Uses the type with the highest q-value",
+			accept:          "application/json;q=0.5, text/csv;q=0.9, application/xml;q=0.1",
+			value:           widgets,
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "created,id,widget_name,owner\n2024-02-01T12:00:00Z,1,Sprocket,Adam\n2024-02-01T12:00:00Z,2,\"Gear, large\",\n",
+		},
+		{
+			name:            "Prefers exact matches over wildcards",
+			accept:          "*/*;q=0.1, application/xml",
+			value:           negotiationWidget{ID: 3, Name: "Cog"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+			wantBody:        `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<negotiationWidget><created>0001-01-01T00:00:00Z</created><id>3</id><name>Cog</name></negotiationWidget>`,
+		},
+		{
+			name:            "Matches subtype wildcards",
+			accept:          "text/*",
+			value:           widgets[1:],
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "created,id,widget_name,owner\n2024-02-01T12:00:00Z,2,\"Gear, large\",\n",
+		},
+		{
+			name:            "Excludes types with a q-value of zero",
+			accept:          "application/json;q=0, */*",
+			value:           negotiationWidget{ID: 3, Name: "Cog"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+		},
+		{
+			name:            "Does not encode slices as XML",
+			accept:          "application/xml, application/json;q=0.5",
+			value:           widgets[:1],
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `[{"created":"2024-02-01T12:00:00Z","id":1,"name":"Sprocket","owner":"Adam"}]`,
+		},
+		{
+			name:            "Skips encoders that cannot encode the value",
+			accept:          "text/csv, application/json;q=0.5",
+			value:           map[string]int{"count": 2},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `{"count":2}`,
+		},
+		{
+			name:            "Returns 406 when nothing matches",
+			accept:          "image/png",
+			value:           widgets,
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: "application/problem+json",
+		},
+		{
+			name:            "Returns 406 when no acceptable encoder can encode the value",
+			accept:          "text/csv",
+			value:           map[string]int{"count": 2},
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: "application/problem+json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			nerdweb.Write(w, r, tt.wantStatus, tt.value)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("wanted content type %q, got %q", tt.wantContentType, got)
+			}
+
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("wanted Vary: Accept, got %q", got)
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("wanted body:\n%s\ngot:\n%s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWriteNotAcceptableListsAvailableTypes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	r.Header.Set("Accept", "image/png")
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Origin, accept")
+	nerdweb.Write(w, r, http.StatusOK, []int{1})
+
+	if !strings.Contains(w.Body.String(), `"available":["application/json","application/xml","text/csv"`) {
+		t.Errorf("wanted the available types listed, got %s", w.Body.String())
+	}
+
+	if got := w.Header().Values("Vary"); len(got) != 1 {
+		t.Errorf("wanted Accept not to be added to Vary twice, got %v", got)
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	nerdweb.RegisterEncoder("application/x-test", func(w io.Writer, value interface{}) error {
+		_, err := io.WriteString(w, "test encoding")
+		return err
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	r.Header.Set("Accept", "application/x-test")
+
+	w := httptest.NewRecorder()
+	nerdweb.Write(w, r, http.StatusCreated, "value")
+
+	if w.Code != http.StatusCreated || w.Body.String() != "test encoding" || w.Header().Get("Content-Type") != "application/x-test" {
+		t.Errorf("wanted the registered encoder to be used, got %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestWriteEncodingFailure(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	w := httptest.NewRecorder()
+
+	nerdweb.Write(w, r, http.StatusOK, func() {})
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("wanted a 500 problem, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestEncodeCSVRejectsUnsupportedValues(t *testing.T) {
+	for _, value := range []interface{}{"text", []int{1, 2}, map[string]string{}} {
+		if err := nerdweb.EncodeCSV(io.Discard, value); !errors.Is(err, nerdweb.ErrEncodingNotSupported) {
+			t.Errorf("wanted ErrEncodingNotSupported for %T, got %v", value, err)
+		}
+	}
+}
+
+func TestEncodeXMLRejectsSlices(t *testing.T) {
+	widgets := []negotiationWidget{{ID: 1}, {ID: 2}}
+
+	for _, value := range []interface{}{widgets, &widgets, [2]int{1, 2}} {
+		if err := nerdweb.EncodeXML(io.Discard, value); !errors.Is(err, nerdweb.ErrEncodingNotSupported) {
+			t.Errorf("wanted ErrEncodingNotSupported for %T, got %v", value, err)
+		}
+	}
+}
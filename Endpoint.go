@@ -7,13 +7,16 @@ import (
 
 /*
 Endpoint defines a single HTTP endpoint. Each endpoint is used
-to configure a Gorilla Mux route.
+to configure a Gorilla Mux route. Set one of HandlerFunc, Handler,
+or ErrorHandlerFunc. Errors returned by an ErrorHandlerFunc are
+written using the server's ErrorMapper.
 */
 type Endpoint struct {
-	Path        string
-	Methods     []string
-	HandlerFunc http.HandlerFunc
-	Handler     http.Handler
+	Path             string
+	Methods          []string
+	HandlerFunc      http.HandlerFunc
+	Handler          http.Handler
+	ErrorHandlerFunc ErrorHandlerFunc
 }
 
 /*
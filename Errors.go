@@ -0,0 +1,194 @@
+package nerdweb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+)
+
+/*
+StatusClientClosedRequest is the non-standard status used when
+the client goes away before the handler finishes.
+*/
+const StatusClientClosedRequest = 499
+
+/*
+HTTPError is an error that carries the HTTP status, an application
+specific code, and optional details to return to the caller. It may
+wrap an underlying error, which is logged but never returned to the
+caller.
+*/
+type HTTPError struct {
+	Code    string
+	Details interface{}
+	Err     error
+	Message string
+	Status  int
+}
+
+/*
+NewHTTPError creates an HTTPError.
+
+Example:
+
+  return nerdweb.NewHTTPError(http.StatusNotFound, "widget_not_found", "widget 4 does not exist")
+*/
+func NewHTTPError(status int, code, message string) *HTTPError {
+	return &HTTPError{
+		Code:    code,
+		Message: message,
+		Status:  status,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+/*
+ErrorHandlerFunc is an HTTP handler that returns an error instead
+of writing error responses itself. Handlers should return before
+writing any part of the response when they fail.
+*/
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+/*
+ErrorMapper turns an error returned by an ErrorHandlerFunc into the
+problem written back to the caller.
+*/
+type ErrorMapper func(r *http.Request, err error) Problem
+
+/*
+DefaultErrorMapper maps errors to problems as follows:
+
+  - An HTTPError anywhere in the error chain uses its status, message,
+    code, and details
+  - ValidationErrors becomes a 422 Unprocessable Entity, with the code
+    "validation_failed" and the field errors as details
+  - context.Canceled becomes a 499 Client Closed Request
+  - context.DeadlineExceeded becomes a 504 Gateway Timeout
+  - Anything else becomes a 500 Internal Server Error, without exposing
+    the error message
+*/
+func DefaultErrorMapper(r *http.Request, err error) Problem {
+	var httpError *HTTPError
+
+	if errors.As(err, &httpError) {
+		status := httpError.Status
+
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+
+		problem := NewProblem(status, httpError.Message)
+		problem.Extensions = map[string]interface{}{}
+
+		if httpError.Code != "" {
+			problem.Extensions["code"] = httpError.Code
+		}
+
+		if httpError.Details != nil {
+			problem.Extensions["details"] = httpError.Details
+		}
+
+		return problem
+	}
+
+	var validationErrors ValidationErrors
+
+	if errors.As(err, &validationErrors) {
+		problem := NewProblem(http.StatusUnprocessableEntity, "the request is not valid")
+		problem.Extensions = map[string]interface{}{
+			"code":    "validation_failed",
+			"details": validationErrors,
+		}
+
+		return problem
+	}
+
+	if errors.Is(err, context.Canceled) {
+		problem := NewProblem(StatusClientClosedRequest, "the request was canceled")
+		problem.Title = "Client Closed Request"
+		return problem
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewProblem(http.StatusGatewayTimeout, "the request timed out")
+	}
+
+	return NewProblem(http.StatusInternalServerError, "an unexpected error occurred")
+}
+
+/*
+HandleErrors adapts an ErrorHandlerFunc to an http.HandlerFunc. Returned
+errors are converted to a problem response with mapper and logged with
+details of the request, using the request-scoped logger when there is
+one. Server errors are logged at the error level, client errors at the
+warning level, and canceled requests at the info level. When mapper is
+nil DefaultErrorMapper is used. If the handler already started writing
+the response, or hijacked the connection, the error is only logged.
+*/
+func HandleErrors(handler ErrorHandlerFunc, mapper ErrorMapper, logger Logger) http.HandlerFunc {
+	if mapper == nil {
+		mapper = DefaultErrorMapper
+	}
+
+	if logger == nil {
+		logger = standardLogger()
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		wrapped, recorder := middlewares.WrapResponseWriter(w)
+		err := handler(wrapped, r)
+
+		if err == nil {
+			return
+		}
+
+		problem := mapper(r, err)
+
+		if problem.Instance == "" {
+			problem.Instance = r.URL.Path
+		}
+
+		entry, ok := middlewares.LoggerFromContext(r)
+
+		if !ok {
+			entry = middlewares.WithRequestFields(logger, r).WithFields(LogFields{
+				"ip":     RealIP(r),
+				"method": r.Method,
+			})
+		}
+
+		entry = entry.WithError(err).WithFields(LogFields{
+			"path":   r.URL.Path,
+			"status": problem.Status,
+		})
+
+		if recorder.WroteHeader || recorder.Hijacked {
+			entry.Error("error handling request after the response was started")
+			return
+		}
+
+		switch {
+		case problem.Status >= 500:
+			entry.Error("error handling request")
+		case problem.Status == StatusClientClosedRequest:
+			entry.Info("request canceled")
+		default:
+			entry.Warn("error handling request")
+		}
+
+		WriteProblem(entry, w, problem)
+	}
+}
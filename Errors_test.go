@@ -0,0 +1,150 @@
+package nerdweb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandleErrors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	detailedErr := nerdweb.NewHTTPError(http.StatusUnprocessableEntity, "invalid_widget", "widget is invalid")
+	detailedErr.Details = map[string]string{"name": "is required"}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "Writes nothing extra when the handler succeeds",
+			err:        nil,
+			wantStatus: http.StatusOK,
+			want:       "",
+		},
+		{
+			name:       "Maps an HTTPError to a problem with code and details",
+			err:        detailedErr,
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       `{"code":"invalid_widget","detail":"widget is invalid","details":{"name":"is required"},"instance":"/widgets","status":422,"title":"Unprocessable Entity","type":"about:blank"}`,
+		},
+		{
+			name:       "Finds wrapped HTTPErrors",
+			err:        fmt.Errorf("saving widget: %w", nerdweb.NewHTTPError(http.StatusConflict, "", "widget already exists")),
+			wantStatus: http.StatusConflict,
+			want:       `{"detail":"widget already exists","instance":"/widgets","status":409,"title":"Conflict","type":"about:blank"}`,
+		},
+		{
+			name:       "Maps validation errors to 422 with field errors",
+			err:        fmt.Errorf("creating widget: %w", nerdweb.ValidationErrors{{Field: "name", Message: "name is required", Rule: "required"}}),
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       `{"code":"validation_failed","detail":"the request is not valid","details":[{"field":"name","message":"name is required","rule":"required"}],"instance":"/widgets","status":422,"title":"Unprocessable Entity","type":"about:blank"}`,
+		},
+		{
+			name:       "Maps context cancellation to 499",
+			err:        fmt.Errorf("query failed: %w", context.Canceled),
+			wantStatus: nerdweb.StatusClientClosedRequest,
+			want:       `{"detail":"the request was canceled","instance":"/widgets","status":499,"title":"Client Closed Request","type":"about:blank"}`,
+		},
+		{
+			name:       "Maps deadlines to 504",
+			err:        context.DeadlineExceeded,
+			wantStatus: http.StatusGatewayTimeout,
+			want:       `{"detail":"the request timed out","instance":"/widgets","status":504,"title":"Gateway Timeout","type":"about:blank"}`,
+		},
+		{
+			name:       "Hides unknown errors behind a 500",
+			err:        errors.New("database password is hunter2"),
+			wantStatus: http.StatusInternalServerError,
+			want:       `{"detail":"an unexpected error occurred","instance":"/widgets","status":500,"title":"Internal Server Error","type":"about:blank"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := nerdweb.NewServer(
+				nerdweb.WithLogger(nerdweb.NewLogrusLogger(logrus.NewEntry(logger))),
+				nerdweb.WithEndpoints(nerdweb.Endpoints{
+					{Path: "/widgets", Methods: []string{http.MethodPost}, ErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request) error {
+						return tt.err
+					}},
+				}),
+			)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/widgets", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if w.Body.String() != tt.want {
+				t.Errorf("want: %s\ngot: %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleErrorsWithCustomMapper(t *testing.T) {
+	errNotFound := errors.New("not found")
+
+	mapper := func(r *http.Request, err error) nerdweb.Problem {
+		if errors.Is(err, errNotFound) {
+			return nerdweb.NewProblem(http.StatusNotFound, "no such thing")
+		}
+
+		return nerdweb.DefaultErrorMapper(r, err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	handler := nerdweb.HandleErrors(func(w http.ResponseWriter, r *http.Request) error {
+		return errNotFound
+	}, mapper, nerdweb.NewLogrusLogger(logrus.NewEntry(logger)))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/thing", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("wanted status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestHandleErrorsAfterResponseStarted(t *testing.T) {
+	output := &strings.Builder{}
+	logger := logrus.New()
+	logger.SetOutput(output)
+
+	handler := nerdweb.HandleErrors(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"partial":`))
+		return errors.New("stream failed")
+	}, nil, nerdweb.NewLogrusLogger(logrus.NewEntry(logger)))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("wanted status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if w.Body.String() != `{"partial":` {
+		t.Errorf("wanted no problem appended to the body, got %s", w.Body.String())
+	}
+
+	if !strings.Contains(output.String(), "stream failed") {
+		t.Errorf("wanted the error to be logged, got %s", output.String())
+	}
+}
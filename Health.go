@@ -0,0 +1,316 @@
+package nerdweb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthStatusFail string = "fail"
+	HealthStatusPass string = "pass"
+	HealthStatusWarn string = "warn"
+)
+
+/*
+HealthChecker checks one dependency of the service, such as a
+database. It returns an error when the dependency is unhealthy.
+*/
+type HealthChecker func(ctx context.Context) error
+
+/*
+HealthCheckOptions configures a registered checker.
+
+Timeout limits how long the checker may run and defaults to the
+Health's DefaultTimeout. When a Critical checker fails the service is
+not ready. Failing non-critical checkers only produce a warning.
+*/
+type HealthCheckOptions struct {
+	Critical bool
+	Timeout  time.Duration
+}
+
+/*
+HealthOptions configures a Health registry.
+
+CacheDuration is how long check results are reused, so frequent probes
+do not overload dependencies. Defaults to 5 seconds; use a negative
+value to turn caching off. DefaultTimeout defaults to 2 seconds.
+LivenessPath, ReadinessPath, and ReportPath are where NewServer serves
+the handlers when given WithHealth, and default to "/healthz",
+"/readyz", and "/health".
+*/
+type HealthOptions struct {
+	CacheDuration  time.Duration
+	DefaultTimeout time.Duration
+	LivenessPath   string
+	ReadinessPath  string
+	ReportPath     string
+}
+
+/*
+HealthCheckResult is the result of one checker.
+*/
+type HealthCheckResult struct {
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+}
+
+/*
+HealthReport is the result of running every checker. Status is "fail"
+when a critical checker failed or the server is shutting down, "warn"
+when only non-critical checkers failed, and "pass" otherwise.
+*/
+type HealthReport struct {
+	CheckedAt    time.Time           `json:"checkedAt"`
+	Checks       []HealthCheckResult `json:"checks"`
+	ShuttingDown bool                `json:"shuttingDown,omitempty"`
+	Status       string              `json:"status"`
+}
+
+type registeredChecker struct {
+	checker HealthChecker
+	name    string
+	options HealthCheckOptions
+}
+
+/*
+Health is a registry of health checkers. It serves liveness, readiness,
+and detailed report endpoints for container orchestrators such as
+Kubernetes.
+*/
+type Health struct {
+	cached       *HealthReport
+	checkers     []registeredChecker
+	lock         sync.Mutex
+	options      HealthOptions
+	shuttingDown atomic.Bool
+}
+
+/*
+NewHealth creates a health registry.
+
+Example:
+
+  health := nerdweb.NewHealth(nerdweb.HealthOptions{CacheDuration: 10 * time.Second})
+  health.Register("database", db.PingContext, nerdweb.HealthCheckOptions{Critical: true})
+  health.Register("cache", pingCache, nerdweb.HealthCheckOptions{Timeout: time.Second})
+
+  _, server := nerdweb.NewServer(nerdweb.WithEndpoints(endpoints), nerdweb.WithHealth(health))
+  err := nerdweb.Run(ctx, server, nerdweb.RunOptions{Health: health})
+*/
+func NewHealth(options HealthOptions) *Health {
+	if options.CacheDuration == 0 {
+		options.CacheDuration = 5 * time.Second
+	}
+
+	if options.DefaultTimeout <= 0 {
+		options.DefaultTimeout = 2 * time.Second
+	}
+
+	if options.LivenessPath == "" {
+		options.LivenessPath = "/healthz"
+	}
+
+	if options.ReadinessPath == "" {
+		options.ReadinessPath = "/readyz"
+	}
+
+	if options.ReportPath == "" {
+		options.ReportPath = "/health"
+	}
+
+	return &Health{options: options}
+}
+
+/*
+Register adds a named checker.
+*/
+func (h *Health) Register(name string, checker HealthChecker, options HealthCheckOptions) {
+	if options.Timeout <= 0 {
+		options.Timeout = h.options.DefaultTimeout
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.checkers = append(h.checkers, registeredChecker{checker: checker, name: name, options: options})
+	h.cached = nil
+}
+
+/*
+SetShuttingDown marks the service as shutting down, which makes the
+readiness check fail. Run calls this when graceful shutdown starts.
+*/
+func (h *Health) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+/*
+Check runs every checker, in parallel, and returns the report. Results
+are reused for the configured cache duration.
+
+Checkers run with their own timeouts, detached from ctx, so a probe
+with a short deadline does not cancel them. If ctx is done before the
+checkers finish, the unfinished checks fail with ctx's error and the
+report is not cached.
+*/
+func (h *Health) Check(ctx context.Context) HealthReport {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var report HealthReport
+
+	if h.cached != nil && time.Since(h.cached.CheckedAt) < h.options.CacheDuration {
+		report = *h.cached
+		report.Checks = append([]HealthCheckResult{}, h.cached.Checks...)
+	} else {
+		report = h.runCheckers(ctx)
+
+		if ctx.Err() == nil {
+			cached := report
+			cached.Checks = append([]HealthCheckResult{}, report.Checks...)
+			h.cached = &cached
+		}
+	}
+
+	if h.shuttingDown.Load() {
+		report.ShuttingDown = true
+		report.Status = HealthStatusFail
+	}
+
+	return report
+}
+
+func (h *Health) runCheckers(ctx context.Context) HealthReport {
+	report := HealthReport{
+		CheckedAt: time.Now(),
+		Checks:    make([]HealthCheckResult, len(h.checkers)),
+		Status:    HealthStatusPass,
+	}
+
+	wg := sync.WaitGroup{}
+
+	for index, registered := range h.checkers {
+		wg.Add(1)
+
+		go func(index int, registered registeredChecker) {
+			defer wg.Done()
+			report.Checks[index] = runChecker(ctx, registered)
+		}(index, registered)
+	}
+
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		switch {
+		case result.Status == HealthStatusPass:
+		case result.Critical:
+			report.Status = HealthStatusFail
+		case report.Status == HealthStatusPass:
+			report.Status = HealthStatusWarn
+		}
+	}
+
+	return report
+}
+
+func runChecker(parent context.Context, registered registeredChecker) (result HealthCheckResult) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), registered.options.Timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	done := make(chan error, 1)
+
+	result = HealthCheckResult{
+		Critical: registered.options.Critical,
+		Name:     registered.name,
+		Status:   HealthStatusPass,
+	}
+
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- fmt.Errorf("checker panicked: %v", recovered)
+			}
+		}()
+
+		done <- registered.checker(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", registered.options.Timeout)
+	case <-parent.Done():
+		err = parent.Err()
+	}
+
+	result.Duration = time.Since(startTime).String()
+
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = HealthStatusFail
+	}
+
+	return result
+}
+
+/*
+LivenessHandler reports that the process is running. It does not run
+the checkers, so a failing dependency does not get the service
+restarted.
+*/
+func (h *Health) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, map[string]string{"status": HealthStatusPass})
+	}
+}
+
+/*
+ReadinessHandler responds with 200 when the service can take traffic
+and 503 when a critical checker fails or the server is shutting down.
+*/
+func (h *Health) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context())
+		writeHealth(w, healthStatusCode(report), map[string]string{"status": report.Status})
+	}
+}
+
+/*
+ReportHandler responds with the full report as JSON, including the
+result of every checker. The status code matches ReadinessHandler.
+*/
+func (h *Health) ReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context())
+		writeHealth(w, healthStatusCode(report), report)
+	}
+}
+
+func healthStatusCode(report HealthReport) int {
+	if report.Status == HealthStatusFail {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusOK
+}
+
+func writeHealth(w http.ResponseWriter, status int, value interface{}) {
+	b, _ := json.Marshal(value)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
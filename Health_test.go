@@ -0,0 +1,247 @@
+package nerdweb_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+func TestHealthCheck(t *testing.T) {
+	passing := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		register   func(health *nerdweb.Health)
+		wantStatus string
+		wantErrors map[string]string
+	}{
+		{
+			name:       "Passes with no checkers",
+			register:   func(health *nerdweb.Health) {},
+			wantStatus: nerdweb.HealthStatusPass,
+		},
+		{
+			name: "Passes when every checker passes",
+			register: func(health *nerdweb.Health) {
+				health.Register("database", passing, nerdweb.HealthCheckOptions{Critical: true})
+				health.Register("cache", passing, nerdweb.HealthCheckOptions{})
+			},
+			wantStatus: nerdweb.HealthStatusPass,
+		},
+		{
+			name: "Warns when a non-critical checker fails",
+			register: func(health *nerdweb.Health) {
+				health.Register("database", passing, nerdweb.HealthCheckOptions{Critical: true})
+				health.Register("cache", failing, nerdweb.HealthCheckOptions{})
+			},
+			wantStatus: nerdweb.HealthStatusWarn,
+			wantErrors: map[string]string{"cache": "connection refused"},
+		},
+		{
+			name: "Fails when a critical checker fails",
+			register: func(health *nerdweb.Health) {
+				health.Register("database", failing, nerdweb.HealthCheckOptions{Critical: true})
+				health.Register("cache", failing, nerdweb.HealthCheckOptions{})
+			},
+			wantStatus: nerdweb.HealthStatusFail,
+			wantErrors: map[string]string{"database": "connection refused", "cache": "connection refused"},
+		},
+		{
+			name: "Fails when a critical checker times out",
+			register: func(health *nerdweb.Health) {
+				health.Register("database", slow, nerdweb.HealthCheckOptions{Critical: true, Timeout: 20 * time.Millisecond})
+			},
+			wantStatus: nerdweb.HealthStatusFail,
+			wantErrors: map[string]string{"database": "check timed out after 20ms"},
+		},
+		{
+			name: "Fails when a critical checker panics",
+			register: func(health *nerdweb.Health) {
+				health.Register("database", func(ctx context.Context) error { panic("nil pool") }, nerdweb.HealthCheckOptions{Critical: true})
+			},
+			wantStatus: nerdweb.HealthStatusFail,
+			wantErrors: map[string]string{"database": "checker panicked: nil pool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := nerdweb.NewHealth(nerdweb.HealthOptions{})
+			tt.register(health)
+
+			report := health.Check(context.Background())
+
+			if report.Status != tt.wantStatus {
+				t.Errorf("wanted status %s, got %s", tt.wantStatus, report.Status)
+			}
+
+			for _, result := range report.Checks {
+				if result.Error != tt.wantErrors[result.Name] {
+					t.Errorf("wanted %s error '%s', got '%s'", result.Name, tt.wantErrors[result.Name], result.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCaching(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheDuration time.Duration
+		wantCalls     int32
+	}{
+		{
+			name:          "Reuses results within the cache duration",
+			cacheDuration: time.Minute,
+			wantCalls:     1,
+		},
+		{
+			name:          "Runs checkers every time when caching is off",
+			cacheDuration: -1,
+			wantCalls:     3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := int32(0)
+			health := nerdweb.NewHealth(nerdweb.HealthOptions{CacheDuration: tt.cacheDuration})
+
+			health.Register("database", func(ctx context.Context) error {
+				atomic.AddInt32(&calls, 1)
+				return nil
+			}, nerdweb.HealthCheckOptions{Critical: true})
+
+			for i := 0; i < 3; i++ {
+				health.Check(context.Background())
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("wanted %d calls, got %d", tt.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	health := nerdweb.NewHealth(nerdweb.HealthOptions{ReportPath: "/health/report"})
+	health.Register("cache", func(ctx context.Context) error { return errors.New("down") }, nerdweb.HealthCheckOptions{})
+
+	router, _ := nerdweb.NewServer(nerdweb.WithHealth(health), nerdweb.WithLogger(nerdweb.NewNopLogger()))
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body := map[string]interface{}{}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		shuttingDown bool
+		wantStatus   int
+		wantHealth   string
+	}{
+		{name: "Liveness passes", path: "/healthz", wantStatus: http.StatusOK, wantHealth: "pass"},
+		{name: "Readiness passes with a warning", path: "/readyz", wantStatus: http.StatusOK, wantHealth: "warn"},
+		{name: "Report includes every check", path: "/health/report", wantStatus: http.StatusOK, wantHealth: "warn"},
+		{name: "Readiness fails while shutting down", path: "/readyz", shuttingDown: true, wantStatus: http.StatusServiceUnavailable, wantHealth: "fail"},
+		{name: "Liveness passes while shutting down", path: "/healthz", shuttingDown: true, wantStatus: http.StatusOK, wantHealth: "pass"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.shuttingDown {
+				health.SetShuttingDown()
+			}
+
+			status, body := get(tt.path)
+
+			if status != tt.wantStatus || body["status"] != tt.wantHealth {
+				t.Errorf("wanted %d %s, got %d %v", tt.wantStatus, tt.wantHealth, status, body["status"])
+			}
+		})
+	}
+
+	_, report := get("/health/report")
+	checks, _ := report["checks"].([]interface{})
+
+	if len(checks) != 1 || checks[0].(map[string]interface{})["error"] != "down" || report["shuttingDown"] != true {
+		t.Errorf("unexpected report %v", report)
+	}
+}
+
+func TestRunMarksHealthShuttingDown(t *testing.T) {
+	health := nerdweb.NewHealth(nerdweb.HealthOptions{})
+	server := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- nerdweb.Run(ctx, server, nerdweb.RunOptions{Health: health, ShutdownDelay: 200 * time.Millisecond})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := health.Check(context.Background()).Status; got != nerdweb.HealthStatusPass {
+		t.Errorf("wanted pass before shutdown, got %s", got)
+	}
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	if got := health.Check(context.Background()).Status; got != nerdweb.HealthStatusFail {
+		t.Errorf("wanted fail during shutdown, got %s", got)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestHealthCheckDoesNotCacheCanceledProbes(t *testing.T) {
+	health := nerdweb.NewHealth(nerdweb.HealthOptions{CacheDuration: time.Minute})
+	health.Register("database", func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, nerdweb.HealthCheckOptions{Critical: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	report := health.Check(ctx)
+
+	if report.Status != nerdweb.HealthStatusFail {
+		t.Errorf("wanted the canceled probe to fail, got %s", report.Status)
+	}
+
+	if report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("wanted the probe's context error, got %q", report.Checks[0].Error)
+	}
+
+	report = health.Check(context.Background())
+
+	if report.Status != nerdweb.HealthStatusPass {
+		t.Errorf("wanted the next check to run again and pass, got %s: %+v", report.Status, report.Checks)
+	}
+}
@@ -0,0 +1,216 @@
+package nerdweb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxJSONBodyBytes int64 = 1 << 20
+
+var (
+	ErrJSONBodyContentType  = errors.New("request content type is not JSON")
+	ErrJSONBodyEmpty        = errors.New("request body is empty")
+	ErrJSONBodySyntax       = errors.New("request body is not valid JSON")
+	ErrJSONBodyTooLarge     = errors.New("request body is too large")
+	ErrJSONBodyTrailingData = errors.New("request body has data after the JSON value")
+	ErrJSONBodyTypeMismatch = errors.New("request body has a value of the wrong type")
+	ErrJSONBodyUnknownField = errors.New("request body has an unknown field")
+)
+
+/*
+JSONBodyOptions configures ReadJSONBodyWithOptions.
+
+MaxBytes limits the size of the body. Defaults to 1MB; use a negative
+value for no limit. DisallowUnknownFields rejects object keys that do
+not match a field in the destination. DisallowTrailingData rejects
+anything but whitespace after the JSON value. RequireContentType
+rejects requests whose Content-Type is not application/json or
+application/*+json. Validate runs Validate on dest after it is
+decoded.
+*/
+type JSONBodyOptions struct {
+	DisallowTrailingData  bool
+	DisallowUnknownFields bool
+	MaxBytes              int64
+	RequireContentType    bool
+	Validate              bool
+}
+
+/*
+StrictJSONBodyOptions returns options that turn on every check,
+including validation, with a 1MB size limit.
+*/
+func StrictJSONBodyOptions() JSONBodyOptions {
+	return JSONBodyOptions{
+		DisallowTrailingData:  true,
+		DisallowUnknownFields: true,
+		MaxBytes:              defaultMaxJSONBodyBytes,
+		RequireContentType:    true,
+		Validate:              true,
+	}
+}
+
+/*
+ReadJSONBodyWithOptions decodes the request body as JSON into dest,
+which must be a pointer. The body is decoded as it is read, and never
+read past the size limit. w is used to tell the server to close the
+connection when the limit is exceeded, and may be nil.
+
+Failures are returned as an *HTTPError, so ErrorHandlerFunc endpoints
+can return them as is. They wrap one of the ErrJSONBody errors, and the
+underlying encoding/json error where there is one:
+
+  - ErrJSONBodyTooLarge: 413, code "body_too_large"
+  - ErrJSONBodyContentType: 415, code "unsupported_media_type"
+  - ErrJSONBodyEmpty: 400, code "empty_body"
+  - ErrJSONBodySyntax: 400, code "invalid_json", with the offset in details
+  - ErrJSONBodyTypeMismatch: 400, code "type_mismatch", with the field
+    path, expected type, and offset in details
+  - ErrJSONBodyUnknownField: 400, code "unknown_field", with the field
+    in details
+  - ErrJSONBodyTrailingData: 400, code "trailing_data"
+
+When validation is on and fails, the ValidationErrors from Validate
+are returned instead.
+
+Example:
+
+  func createWidget(w http.ResponseWriter, r *http.Request) error {
+    widget := Widget{}
+
+    if err := nerdweb.ReadJSONBodyWithOptions(w, r, &widget, nerdweb.StrictJSONBodyOptions()); err != nil {
+      return err
+    }
+
+    ...
+  }
+*/
+func ReadJSONBodyWithOptions(w http.ResponseWriter, r *http.Request, dest interface{}, options JSONBodyOptions) error {
+	if options.RequireContentType && !isJSONContentType(r.Header.Get("Content-Type")) {
+		return newHTTPErrorWithDetails(http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/json", nil, ErrJSONBodyContentType, nil)
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "empty_body", "request body is empty", nil, ErrJSONBodyEmpty, nil)
+	}
+
+	if options.MaxBytes == 0 {
+		options.MaxBytes = defaultMaxJSONBodyBytes
+	}
+
+	body := r.Body
+
+	if options.MaxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, options.MaxBytes)
+	}
+
+	decoder := json.NewDecoder(body)
+
+	if options.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(dest); err != nil {
+		return jsonDecodeError(err)
+	}
+
+	if options.DisallowTrailingData {
+		var trailing json.RawMessage
+
+		if err := decoder.Decode(&trailing); !errors.Is(err, io.EOF) {
+			var maxBytesError *http.MaxBytesError
+
+			if errors.As(err, &maxBytesError) {
+				return jsonDecodeError(err)
+			}
+
+			return newHTTPErrorWithDetails(http.StatusBadRequest, "trailing_data", "request body must contain a single JSON value", nil, ErrJSONBodyTrailingData, err)
+		}
+	}
+
+	if options.Validate {
+		return Validate(dest)
+	}
+
+	return nil
+}
+
+func jsonDecodeError(err error) error {
+	var (
+		maxBytesError  *http.MaxBytesError
+		syntaxError    *json.SyntaxError
+		unmarshalError *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return newHTTPErrorWithDetails(http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("request body must not be larger than %d bytes", maxBytesError.Limit),
+			map[string]interface{}{"limit": maxBytesError.Limit}, ErrJSONBodyTooLarge, err)
+
+	case errors.Is(err, io.EOF):
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "empty_body", "request body is empty", nil, ErrJSONBodyEmpty, err)
+
+	case errors.As(err, &syntaxError):
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "invalid_json",
+			fmt.Sprintf("request body is not valid JSON at offset %d", syntaxError.Offset),
+			map[string]interface{}{"offset": syntaxError.Offset}, ErrJSONBodySyntax, err)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "invalid_json", "request body is not valid JSON", nil, ErrJSONBodySyntax, err)
+
+	case errors.As(err, &unmarshalError):
+		details := map[string]interface{}{
+			"expected": unmarshalError.Type.String(),
+			"offset":   unmarshalError.Offset,
+		}
+
+		message := fmt.Sprintf("request body has a %s where a %s was expected", unmarshalError.Value, unmarshalError.Type)
+
+		if unmarshalError.Field != "" {
+			details["field"] = unmarshalError.Field
+			message = fmt.Sprintf("field %q must be a %s", unmarshalError.Field, unmarshalError.Type)
+		}
+
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "type_mismatch", message, details, ErrJSONBodyTypeMismatch, err)
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "unknown_field", fmt.Sprintf("field %q is not allowed", field),
+			map[string]interface{}{"field": field}, ErrJSONBodyUnknownField, err)
+
+	default:
+		return fmt.Errorf("error decoding request body: %w", err)
+	}
+}
+
+func newHTTPErrorWithDetails(status int, code, message string, details map[string]interface{}, kind error, cause error) *HTTPError {
+	result := NewHTTPError(status, code, message)
+	result.Err = kind
+
+	if details != nil {
+		result.Details = details
+	}
+
+	if cause != nil {
+		result.Err = fmt.Errorf("%w: %w", kind, cause)
+	}
+
+	return result
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
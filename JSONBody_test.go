@@ -0,0 +1,212 @@
+package nerdweb_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+func TestReadJSONBodyWithOptions(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+
+	type sampleStruct struct {
+		Address address `json:"address"`
+		Age     int     `json:"age"`
+		Name    string  `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		options     nerdweb.JSONBodyOptions
+		wantErr     error
+		wantStatus  int
+		wantCode    string
+		wantField   string
+		wantName    string
+	}{
+		{
+			name:        "Decodes a valid body",
+			body:        `{"name":"Adam","age":10}`,
+			contentType: "application/json",
+			options:     nerdweb.StrictJSONBodyOptions(),
+			wantName:    "Adam",
+		},
+		{
+			name:        "Accepts structured JSON content types with parameters",
+			body:        `{"name":"Adam"}`,
+			contentType: "application/merge-patch+json; charset=utf-8",
+			options:     nerdweb.StrictJSONBodyOptions(),
+			wantName:    "Adam",
+		},
+		{
+			name:        "Rejects a body over the size limit",
+			body:        `{"name":"` + strings.Repeat("a", 100) + `"}`,
+			contentType: "application/json",
+			options:     nerdweb.JSONBodyOptions{MaxBytes: 32},
+			wantErr:     nerdweb.ErrJSONBodyTooLarge,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    "body_too_large",
+		},
+		{
+			name:       "Rejects a missing content type when required",
+			body:       `{"name":"Adam"}`,
+			options:    nerdweb.JSONBodyOptions{RequireContentType: true},
+			wantErr:    nerdweb.ErrJSONBodyContentType,
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   "unsupported_media_type",
+		},
+		{
+			name:        "Rejects a non JSON content type when required",
+			body:        `{"name":"Adam"}`,
+			contentType: "text/plain",
+			options:     nerdweb.JSONBodyOptions{RequireContentType: true},
+			wantErr:     nerdweb.ErrJSONBodyContentType,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    "unsupported_media_type",
+		},
+		{
+			name:     "Allows any content type by default",
+			body:     `{"name":"Adam"}`,
+			options:  nerdweb.JSONBodyOptions{},
+			wantName: "Adam",
+		},
+		{
+			name:       "Rejects an empty body",
+			body:       "",
+			options:    nerdweb.JSONBodyOptions{},
+			wantErr:    nerdweb.ErrJSONBodyEmpty,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "empty_body",
+		},
+		{
+			name:       "Rejects invalid JSON",
+			body:       `{"name":}`,
+			options:    nerdweb.JSONBodyOptions{},
+			wantErr:    nerdweb.ErrJSONBodySyntax,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_json",
+		},
+		{
+			name:       "Rejects truncated JSON",
+			body:       `{"name":"Adam"`,
+			options:    nerdweb.JSONBodyOptions{},
+			wantErr:    nerdweb.ErrJSONBodySyntax,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_json",
+		},
+		{
+			name:       "Reports the field path of a type mismatch",
+			body:       `{"address":{"city":12}}`,
+			options:    nerdweb.JSONBodyOptions{},
+			wantErr:    nerdweb.ErrJSONBodyTypeMismatch,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "type_mismatch",
+			wantField:  "address.city",
+		},
+		{
+			name:       "Rejects unknown fields when configured",
+			body:       `{"name":"Adam","nickname":"A"}`,
+			options:    nerdweb.JSONBodyOptions{DisallowUnknownFields: true},
+			wantErr:    nerdweb.ErrJSONBodyUnknownField,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "unknown_field",
+			wantField:  "nickname",
+		},
+		{
+			name:     "Ignores unknown fields by default",
+			body:     `{"name":"Adam","nickname":"A"}`,
+			options:  nerdweb.JSONBodyOptions{},
+			wantName: "Adam",
+		},
+		{
+			name:       "Rejects trailing data when configured",
+			body:       `{"name":"Adam"}{"name":"Bob"}`,
+			options:    nerdweb.JSONBodyOptions{DisallowTrailingData: true},
+			wantErr:    nerdweb.ErrJSONBodyTrailingData,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "trailing_data",
+		},
+		{
+			name:     "Allows trailing whitespace when trailing data is not allowed",
+			body:     "{\"name\":\"Adam\"}\n  ",
+			options:  nerdweb.JSONBodyOptions{DisallowTrailingData: true},
+			wantName: "Adam",
+		},
+		{
+			name:     "Ignores trailing data by default",
+			body:     `{"name":"Adam"} garbage`,
+			options:  nerdweb.JSONBodyOptions{},
+			wantName: "Adam",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			dest := sampleStruct{}
+			got := nerdweb.ReadJSONBodyWithOptions(httptest.NewRecorder(), r, &dest, tt.options)
+
+			if tt.wantErr == nil {
+				if got != nil {
+					t.Fatalf("wanted no error, got %v", got)
+				}
+
+				if dest.Name != tt.wantName {
+					t.Errorf("wanted name %q, got %q", tt.wantName, dest.Name)
+				}
+
+				return
+			}
+
+			if !errors.Is(got, tt.wantErr) {
+				t.Fatalf("wanted error %v, got %v", tt.wantErr, got)
+			}
+
+			var httpError *nerdweb.HTTPError
+
+			if !errors.As(got, &httpError) {
+				t.Fatalf("wanted *nerdweb.HTTPError, got %T", got)
+			}
+
+			if httpError.Status != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, httpError.Status)
+			}
+
+			if httpError.Code != tt.wantCode {
+				t.Errorf("wanted code %q, got %q", tt.wantCode, httpError.Code)
+			}
+
+			if tt.wantField != "" {
+				details, _ := httpError.Details.(map[string]interface{})
+
+				if details["field"] != tt.wantField {
+					t.Errorf("wanted field %q, got %v", tt.wantField, details["field"])
+				}
+			}
+		})
+	}
+}
+
+func TestReadJSONBodyWithOptionsNilResponseWriter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"`+strings.Repeat("a", 100)+`"}`))
+	dest := map[string]string{}
+
+	got := nerdweb.ReadJSONBodyWithOptions(nil, r, &dest, nerdweb.JSONBodyOptions{MaxBytes: 16})
+
+	if !errors.Is(got, nerdweb.ErrJSONBodyTooLarge) {
+		t.Errorf("wanted ErrJSONBodyTooLarge, got %v", got)
+	}
+}
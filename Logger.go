@@ -0,0 +1,75 @@
+package nerdweb
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+Logger is the logging interface used by nerdweb. See middlewares.Logger.
+*/
+type Logger = middlewares.Logger
+
+/*
+LogFields are structured fields added to a log entry.
+*/
+type LogFields = middlewares.LogFields
+
+/*
+NewLogrusLogger adapts a logrus entry to the Logger interface.
+*/
+func NewLogrusLogger(entry *logrus.Entry) Logger {
+	return middlewares.NewLogrusLogger(entry)
+}
+
+/*
+NewSlogLogger adapts a log/slog logger to the Logger interface.
+*/
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return middlewares.NewSlogLogger(logger)
+}
+
+/*
+NewNopLogger returns a Logger that discards everything.
+*/
+func NewNopLogger() Logger {
+	return middlewares.NewNopLogger()
+}
+
+/*
+LoggerFromRequest returns the request-scoped logger attached by the
+RequestScopedLogger middleware. It includes the request ID, IP address,
+method, route template, and authenticated subject. If the request has
+no logger, a logger for the logrus standard logger is returned.
+*/
+func LoggerFromRequest(r *http.Request) Logger {
+	if logger, ok := middlewares.LoggerFromContext(r); ok {
+		return logger
+	}
+
+	return standardLogger()
+}
+
+/*
+resolveLogger returns logger when it is not nil. Otherwise the
+request-scoped logger is found through the response writer, falling
+back to the logrus standard logger.
+*/
+func resolveLogger(logger Logger, w http.ResponseWriter) Logger {
+	if logger != nil {
+		return logger
+	}
+
+	if requestLogger, ok := middlewares.LoggerFromResponseWriter(w); ok {
+		return requestLogger
+	}
+
+	return standardLogger()
+}
+
+func standardLogger() Logger {
+	return NewLogrusLogger(logrus.NewEntry(logrus.StandardLogger()))
+}
@@ -0,0 +1,52 @@
+package nerdweb_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoggerFromRequest(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(output)
+
+	router, _ := nerdweb.NewServer(
+		nerdweb.WithLogger(nerdweb.NewLogrusLogger(logrus.NewEntry(logger).WithField("who", "testing"))),
+		nerdweb.WithEndpoints(nerdweb.Endpoints{
+			{Path: "/widget/{id}", Methods: []string{http.MethodGet}, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				nerdweb.LoggerFromRequest(r).Info("getting widget")
+				nerdweb.WriteJSON(nil, w, http.StatusOK, func() {})
+			}},
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/widget/4", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("wanted 2 log lines, got %d: %s", len(lines), output.String())
+	}
+
+	for _, line := range lines {
+		for _, want := range []string{"who=testing", "requestID=req-1", `route="/widget/{id}"`, "method=GET"} {
+			if !strings.Contains(line, want) {
+				t.Errorf("expected '%s' in log line: %s", want, line)
+			}
+		}
+	}
+}
+
+func TestLoggerFromRequestWithoutMiddleware(t *testing.T) {
+	if nerdweb.LoggerFromRequest(httptest.NewRequest(http.MethodGet, "/", nil)) == nil {
+		t.Errorf("expected a fallback logger")
+	}
+}
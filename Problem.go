@@ -0,0 +1,92 @@
+package nerdweb
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+Problem is an RFC 7807 problem details object. Extensions are
+additional members written alongside the standard ones.
+*/
+type Problem struct {
+	Detail     string
+	Extensions map[string]interface{}
+	Instance   string
+	Status     int
+	Title      string
+	Type       string
+}
+
+/*
+NewProblem creates a problem for an HTTP status. The type is
+"about:blank" and the title is the standard status text.
+*/
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Detail: detail,
+		Status: status,
+		Title:  http.StatusText(status),
+		Type:   "about:blank",
+	}
+}
+
+/*
+MarshalJSON writes the standard members and the extension members
+as a single JSON object. Extensions never override standard members.
+*/
+func (p Problem) MarshalJSON() ([]byte, error) {
+	result := make(map[string]interface{}, len(p.Extensions)+5)
+
+	for key, value := range p.Extensions {
+		result[key] = value
+	}
+
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			result[key] = value
+		} else {
+			delete(result, key)
+		}
+	}
+
+	setIfNotEmpty("type", p.Type)
+	setIfNotEmpty("title", p.Title)
+	setIfNotEmpty("detail", p.Detail)
+	setIfNotEmpty("instance", p.Instance)
+
+	if p.Status != 0 {
+		result["status"] = p.Status
+	} else {
+		delete(result, "status")
+	}
+
+	return json.Marshal(result)
+}
+
+/*
+WriteProblem writes a problem to the response writer as
+application/problem+json. When logger is nil the request-scoped
+logger is used.
+*/
+func WriteProblem(logger Logger, w http.ResponseWriter, problem Problem) {
+	var (
+		err error
+		b   []byte
+	)
+
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+
+	if b, err = json.Marshal(problem); err != nil {
+		resolveLogger(logger, w).WithError(err).Error("error marshaling problem for writing")
+
+		problem = NewProblem(http.StatusInternalServerError, "Error marshaling problem for writing")
+		b, _ = json.Marshal(problem)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	_, _ = w.Write(b)
+}
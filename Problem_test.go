@@ -0,0 +1,77 @@
+package nerdweb_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWriteProblem(t *testing.T) {
+	logger := nerdweb.NewLogrusLogger(logrus.New().WithField("who", "testing"))
+
+	tests := []struct {
+		name       string
+		problem    nerdweb.Problem
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "Writes a problem created from a status",
+			problem:    nerdweb.NewProblem(http.StatusNotFound, "widget 4 does not exist"),
+			wantStatus: http.StatusNotFound,
+			want:       `{"detail":"widget 4 does not exist","status":404,"title":"Not Found","type":"about:blank"}`,
+		},
+		{
+			name: "Writes extension members without overriding standard members",
+			problem: nerdweb.Problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Status:   http.StatusForbidden,
+				Instance: "/account/12345/msgs/abc",
+				Extensions: map[string]interface{}{
+					"balance": 30,
+					"status":  "ignored",
+				},
+			},
+			wantStatus: http.StatusForbidden,
+			want:       `{"balance":30,"instance":"/account/12345/msgs/abc","status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`,
+		},
+		{
+			name:       "Defaults to a 500 status",
+			problem:    nerdweb.Problem{Title: "Oops"},
+			wantStatus: http.StatusInternalServerError,
+			want:       `{"status":500,"title":"Oops"}`,
+		},
+		{
+			name: "Writes a generic problem when extensions cannot be marshaled",
+			problem: nerdweb.Problem{
+				Status:     http.StatusBadRequest,
+				Extensions: map[string]interface{}{"bad": func() {}},
+			},
+			wantStatus: http.StatusInternalServerError,
+			want:       `{"detail":"Error marshaling problem for writing","status":500,"title":"Internal Server Error","type":"about:blank"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			nerdweb.WriteProblem(logger, w, tt.problem)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("wanted content type application/problem+json, got %s", w.Header().Get("Content-Type"))
+			}
+
+			if w.Body.String() != tt.want {
+				t.Errorf("want: %s\ngot: %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,8 +2,6 @@ package nerdweb
 
 import (
 	"net/http"
-	"sort"
-	"time"
 
 	"github.com/app-nerds/nerdweb/v2/middlewares"
 	"github.com/gorilla/mux"
@@ -11,11 +9,18 @@ import (
 
 /*
 RESTConfig is used to configure a router for basic REST servers
+
+CORS controls the CORS policy. When nil, all origins, methods, and
+headers are allowed. ErrorMapper and Logger are used for endpoints
+with an ErrorHandlerFunc.
 */
 type RESTConfig struct {
+	CORS         *middlewares.CORSConfig
 	Endpoints    Endpoints
+	ErrorMapper  ErrorMapper
 	Host         string
 	IdleTimeout  int
+	Logger       Logger
 	ReadTimeout  int
 	WriteTimeout int
 }
@@ -41,28 +46,7 @@ some preconfigured defaults for REST applications. The HTTP server
 is setup to use the resulting router.
 */
 func NewRESTRouterAndServer(config RESTConfig) (*mux.Router, *http.Server) {
-	router := mux.NewRouter()
-	server := &http.Server{
-		Addr:         config.Host,
-		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
-		Handler:      router,
-	}
-
-	router.Use(middlewares.AccessControl(middlewares.AllowAllOrigins, middlewares.AllowAllMethods, middlewares.AllowAllHeaders))
-
-	sort.Sort(config.Endpoints)
-
-	for _, e := range config.Endpoints {
-		if e.HandlerFunc != nil {
-			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
-		} else {
-			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
-		}
-	}
-
-	return router, server
+	return NewServer(restOptions(config)...)
 }
 
 /*
@@ -71,25 +55,22 @@ with some preconfigured defaults for REST applications. The HTTP
 server is setup to use the resulting router.
 */
 func NewRESTServer(router *mux.Router, config RESTConfig) *http.Server {
-	server := &http.Server{
-		Addr:         config.Host,
-		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
-		Handler:      router,
-	}
-
-	router.Use(middlewares.AccessControl(middlewares.AllowAllOrigins, middlewares.AllowAllMethods, middlewares.AllowAllHeaders))
+	_, server := NewServer(append(restOptions(config), WithRouter(router))...)
+	return server
+}
 
-	sort.Sort(config.Endpoints)
+func restOptions(config RESTConfig) []Option {
+	opts := []Option{
+		WithHost(config.Host),
+		WithTimeouts(config.IdleTimeout, config.ReadTimeout, config.WriteTimeout),
+		WithEndpoints(config.Endpoints),
+		WithErrorMapper(config.ErrorMapper),
+		WithLogger(config.Logger),
+	}
 
-	for _, e := range config.Endpoints {
-		if e.HandlerFunc != nil {
-			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
-		} else {
-			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
-		}
+	if config.CORS != nil {
+		opts = append(opts, WithCORS(*config.CORS))
 	}
 
-	return server
+	return opts
 }
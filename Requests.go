@@ -4,39 +4,46 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/app-nerds/nerdweb/v2/middlewares"
 	"github.com/sirupsen/logrus"
 )
 
 /*
-RealIP attempts to return the IP address of the caller. The result
-will default to the RemoteAddr from http.Request. It will also
-check the request headers for an "X-Forwarded-For" value and use
-that. This is useful for when requests come through proxies or other
-non-direct means.
+RealIP attempts to return the IP address of the caller, without a port.
+The result defaults to the RemoteAddr from http.Request. When the request
+comes from a trusted proxy the forwarding header it sets, X-Forwarded-For
+by default, is used instead. This is useful for when requests come through
+proxies or other non-direct means. Trusted proxies are configured with
+WithIPResolver or middlewares.TrustedProxies; without either only
+loopback proxies are trusted.
 */
 func RealIP(r *http.Request) string {
-	result := r.RemoteAddr
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-
-	if xForwardedFor != "" {
-		result = xForwardedFor
+	if addr := middlewares.IPResolverFromContext(r).Resolve(r); addr.IsValid() {
+		return addr.String()
 	}
 
-	return result
+	return r.RemoteAddr
 }
 
 /*
 ValidateHTTPMethod checks the request METHOD against expectedMethod. If
-they do not match an error message is written back to the client.
+they do not match a problem response is written back to the client.
+When logger is nil the request-scoped logger is used.
 */
 func ValidateHTTPMethod(r *http.Request, w http.ResponseWriter, expectedMethod string, logger *logrus.Entry) error {
+	return ValidateHTTPMethodWith(r, w, expectedMethod, NewLogrusLogger(logger))
+}
+
+/*
+ValidateHTTPMethodWith is ValidateHTTPMethod for any Logger implementation.
+*/
+func ValidateHTTPMethodWith(r *http.Request, w http.ResponseWriter, expectedMethod string, logger Logger) error {
 	if r.Method != expectedMethod {
+		if logger == nil {
+			logger = LoggerFromRequest(r)
+		}
 
-		WriteJSON(logger, w, http.StatusMethodNotAllowed, struct {
-			Message string `json:"message"`
-		}{
-			Message: "method not allowed",
-		})
+		WriteProblem(logger, w, NewProblem(http.StatusMethodNotAllowed, "method not allowed"))
 
 		return fmt.Errorf("invalid method")
 	}
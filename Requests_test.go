@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/app-nerds/nerdweb/v2"
+	"github.com/app-nerds/nerdweb/v2/middlewares"
 	"github.com/sirupsen/logrus"
 )
 
@@ -33,6 +34,59 @@ func TestRealIP(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Strips the port from RemoteAddr",
+			want: "203.0.113.9",
+			args: args{
+				r: &http.Request{
+					RemoteAddr: "203.0.113.9:52100",
+					Header:     http.Header{},
+				},
+			},
+		},
+		{
+			name: "Ignores X-Forwarded-For from untrusted callers",
+			want: "203.0.113.9",
+			args: args{
+				r: &http.Request{
+					RemoteAddr: "203.0.113.9:52100",
+					Header:     http.Header{"X-Forwarded-For": []string{"198.51.100.1"}},
+				},
+			},
+		},
+		{
+			name: "Returns the first untrusted address in an X-Forwarded-For chain",
+			want: "198.51.100.7",
+			args: args{
+				r: &http.Request{
+					RemoteAddr: "127.0.0.1:40000",
+					Header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4, 198.51.100.7, 127.0.0.5"}},
+				},
+			},
+		},
+		{
+			name: "Does not trust private networks by default",
+			want: "10.0.0.2",
+			args: args{
+				r: &http.Request{
+					RemoteAddr: "10.0.0.2:40000",
+					Header:     http.Header{"X-Forwarded-For": []string{"198.51.100.7"}},
+				},
+			},
+		},
+		{
+			name: "Ignores a Forwarded header sent by the client",
+			want: "198.51.100.7",
+			args: args{
+				r: &http.Request{
+					RemoteAddr: "127.0.0.1:40000",
+					Header: http.Header{
+						"Forwarded":       []string{"for=1.2.3.4"},
+						"X-Forwarded-For": []string{"198.51.100.7"},
+					},
+				},
+			},
+		},
 		{
 			name: "Returns X-Forwarded-For when present",
 			want: "127.0.0.2",
@@ -56,6 +110,26 @@ func TestRealIP(t *testing.T) {
 	}
 }
 
+func TestRealIPWithIPResolver(t *testing.T) {
+	resolver, _ := middlewares.NewIPResolver(middlewares.IPResolverOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+	router, _ := nerdweb.NewServer(nerdweb.WithIPResolver(resolver), nerdweb.WithLogger(nerdweb.NewNopLogger()))
+
+	router.HandleFunc("/ip", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(nerdweb.RealIP(r)))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	r.RemoteAddr = "10.0.0.2:40000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7, 10.0.0.1")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if got := w.Body.String(); got != "198.51.100.7" {
+		t.Errorf("wanted 198.51.100.7, got %s", got)
+	}
+}
+
 func TestValidateHTTPMethod(t *testing.T) {
 	type args struct {
 		r              *http.Request
@@ -86,7 +160,7 @@ func TestValidateHTTPMethod(t *testing.T) {
 		{
 			name:             "Returns an error and writes a message to the write when the method is invalid",
 			wantErr:          true,
-			wantErrorMessage: `{"message":"method not allowed"}`,
+			wantErrorMessage: `{"detail":"method not allowed","status":405,"title":"Method Not Allowed","type":"about:blank"}`,
 			args: args{
 				r: &http.Request{
 					Method: "GET",
@@ -31,32 +31,32 @@ func ReadJSONBody(r *http.Request, dest interface{}) error {
 }
 
 /*
-WriteJSON writes JSON content to the response writer.
+WriteJSON writes JSON content to the response writer. If value cannot
+be marshaled a problem response is written instead. When logger is nil
+the request-scoped logger is used.
 */
 func WriteJSON(logger *logrus.Entry, w http.ResponseWriter, status int, value interface{}) {
+	WriteJSONWith(NewLogrusLogger(logger), w, status, value)
+}
+
+/*
+WriteJSONWith is WriteJSON for any Logger implementation.
+*/
+func WriteJSONWith(logger Logger, w http.ResponseWriter, status int, value interface{}) {
 	var (
 		err error
 		b   []byte
 	)
 
-	w.Header().Set("Content-Type", "application/json")
-
 	if b, err = json.Marshal(value); err != nil {
+		logger = resolveLogger(logger, w)
 		logger.WithError(err).Error("error marshaling value for writing")
-
-		b, _ = json.Marshal(struct {
-			Message    string `json:"message"`
-			Suggestion string `json:"suggestion"`
-		}{
-			Message:    "Error marshaling value for writing",
-			Suggestion: "See error log for more information",
-		})
-
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(w, "%s", string(b))
+		WriteProblem(logger, w, NewProblem(http.StatusInternalServerError, "Error marshaling value for writing. See error log for more information"))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+
 	if status > 299 {
 		w.WriteHeader(status)
 	}
@@ -65,9 +65,17 @@ func WriteJSON(logger *logrus.Entry, w http.ResponseWriter, status int, value in
 }
 
 /*
-WriteString writes string content to the response writer.
+WriteString writes string content to the response writer. logger
+may be nil.
 */
 func WriteString(logger *logrus.Entry, w http.ResponseWriter, status int, value string) {
+	WriteStringWith(NewLogrusLogger(logger), w, status, value)
+}
+
+/*
+WriteStringWith is WriteString for any Logger implementation.
+*/
+func WriteStringWith(logger Logger, w http.ResponseWriter, status int, value string) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(status)
 	_, _ = fmt.Fprintf(w, "%s", value)
@@ -112,10 +112,10 @@ func TestWriteJSON(t *testing.T) {
 			},
 		},
 		{
-			name:            "Writes an error message when there is a problem marshaling JSON data",
+			name:            "Writes a problem when there is a problem marshaling JSON data",
 			wantStatus:      http.StatusInternalServerError,
-			wantContentType: "application/json",
-			want:            `{"message":"Error marshaling value for writing","suggestion":"See error log for more information"}`,
+			wantContentType: "application/problem+json",
+			want:            `{"detail":"Error marshaling value for writing. See error log for more information","status":500,"title":"Internal Server Error","type":"about:blank"}`,
 			args: args{
 				w:      httptest.NewRecorder(),
 				status: http.StatusInternalServerError,
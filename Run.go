@@ -0,0 +1,151 @@
+package nerdweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/signal"
+	"time"
+)
+
+/*
+Hook is a function run at a point in the server lifecycle, such as
+opening or closing database pools.
+*/
+type Hook func(ctx context.Context) error
+
+/*
+RunOptions configures how Run starts and stops an HTTP server.
+*/
+type RunOptions struct {
+	// Health, when set, is marked as shutting down as soon as shutdown
+	// starts, so readiness checks fail while requests drain.
+	Health *Health
+
+	// OnShutdown hooks run in order after the server has stopped accepting
+	// requests. All hooks run, even if one fails.
+	OnShutdown []Hook
+
+	// OnStart hooks run in order before the server starts listening. If
+	// a hook fails the server is not started.
+	OnStart []Hook
+
+	// ShutdownDelay is how long to keep serving after readiness starts
+	// failing, so load balancers can stop sending new requests before
+	// the server stops accepting them. Defaults to no delay.
+	ShutdownDelay time.Duration
+
+	// ShutdownTimeout is how long in-flight requests and shutdown hooks
+	// are given to finish. Defaults to 10 seconds.
+	ShutdownTimeout time.Duration
+}
+
+/*
+Run starts the HTTP server and blocks until the process receives an
+interrupt or terminate signal, ctx is cancelled, or the server fails.
+The server is then gracefully shut down, and the OnShutdown hooks
+are run. Every error encountered is returned as a single error.
+
+When server.TLSConfig is set the server serves HTTPS, and Addr
+defaults to ":https". The certificates must be in TLSConfig, through
+Certificates or GetCertificate.
+
+Example:
+
+  _, server := nerdweb.NewServer(nerdweb.WithHost("localhost:8080"), nerdweb.WithEndpoints(endpoints))
+
+  if err := nerdweb.Run(context.Background(), server, nerdweb.RunOptions{}); err != nil {
+    logger.WithError(err).Fatal("server error")
+  }
+*/
+func Run(ctx context.Context, server *http.Server, options RunOptions) error {
+	var (
+		err      error
+		errs     []error
+		listener net.Listener
+	)
+
+	if options.ShutdownTimeout <= 0 {
+		options.ShutdownTimeout = 10 * time.Second
+	}
+
+	for _, hook := range options.OnStart {
+		if err = hook(ctx); err != nil {
+			return fmt.Errorf("error running start hook: %w", err)
+		}
+	}
+
+	addr := server.Addr
+
+	if addr == "" {
+		addr = ":http"
+
+		if server.TLSConfig != nil {
+			addr = ":https"
+		}
+	}
+
+	if listener, err = net.Listen("tcp", addr); err != nil {
+		errs = append(errs, fmt.Errorf("error starting server: %w", err))
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), options.ShutdownTimeout)
+		defer cancel()
+
+		return errors.Join(append(errs, runHooks(shutdownCtx, options.OnShutdown)...)...)
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		if server.TLSConfig != nil {
+			serveErr <- server.ServeTLS(listener, "", "")
+			return
+		}
+
+		serveErr <- server.Serve(listener)
+	}()
+
+	quit := WaitForKill()
+	defer signal.Stop(quit)
+
+	select {
+	case <-quit:
+	case <-ctx.Done():
+	case err = <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, fmt.Errorf("error running server: %w", err))
+		}
+	}
+
+	if options.Health != nil {
+		options.Health.SetShuttingDown()
+	}
+
+	if options.ShutdownDelay > 0 {
+		time.Sleep(options.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), options.ShutdownTimeout)
+	defer cancel()
+
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("error shutting down server: %w", err))
+	}
+
+	errs = append(errs, runHooks(shutdownCtx, options.OnShutdown)...)
+	return errors.Join(errs...)
+}
+
+func runHooks(ctx context.Context, hooks []Hook) []error {
+	var errs []error
+
+	for i, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error running shutdown hook %d: %w", i, err))
+		}
+	}
+
+	return errs
+}
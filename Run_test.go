@@ -0,0 +1,120 @@
+package nerdweb_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+func TestRun(t *testing.T) {
+	calls := []string{}
+	hook := func(name string, err error) nerdweb.Hook {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	closeErr := errors.New("close failed")
+	flushErr := errors.New("flush failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	err := nerdweb.Run(ctx, server, nerdweb.RunOptions{
+		OnStart:    []nerdweb.Hook{hook("open", nil)},
+		OnShutdown: []nerdweb.Hook{hook("close", closeErr), hook("flush", flushErr)},
+	})
+
+	wantCalls := []string{"open", "close", "flush"}
+
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("wanted hooks %v, got %v", wantCalls, calls)
+	}
+
+	if !errors.Is(err, closeErr) || !errors.Is(err, flushErr) {
+		t.Errorf("expected both shutdown hook errors, got %v", err)
+	}
+}
+
+func TestRunStartHookFailure(t *testing.T) {
+	startErr := errors.New("no database")
+	server := &http.Server{Addr: "127.0.0.1:0"}
+
+	err := nerdweb.Run(context.Background(), server, nerdweb.RunOptions{
+		OnStart: []nerdweb.Hook{func(ctx context.Context) error { return startErr }},
+	})
+
+	if !errors.Is(err, startErr) {
+		t.Errorf("wanted start hook error, got %v", err)
+	}
+}
+
+func TestRunServesTLS(t *testing.T) {
+	certificates := httptest.NewTLSServer(http.NotFoundHandler())
+	client := certificates.Client()
+	certificates.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("error finding a free port: %v", err)
+	}
+
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	server := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+		TLSConfig: &tls.Config{Certificates: certificates.TLS.Certificates},
+	}
+
+	go func() {
+		done <- nerdweb.Run(ctx, server, nerdweb.RunOptions{})
+	}()
+
+	var response *http.Response
+
+	for i := 0; i < 50; i++ {
+		if response, err = client.Get("https://" + addr); err == nil {
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatalf("wanted an HTTPS response, got %v", err)
+	}
+
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusTeapot || response.TLS == nil {
+		t.Errorf("wanted a %d response over TLS, got %d", http.StatusTeapot, response.StatusCode)
+	}
+
+	cancel()
+
+	if err = <-done; err != nil {
+		t.Errorf("wanted no error, got %v", err)
+	}
+}
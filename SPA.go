@@ -6,9 +6,7 @@ import (
 	"io/fs"
 	"net/http"
 	"os"
-	"sort"
 	"strings"
-	"time"
 
 	"github.com/app-nerds/nerdweb/v2/middlewares"
 	"github.com/gorilla/mux"
@@ -16,19 +14,28 @@ import (
 
 /*
 SPAConfig is used to configure a single page application router
+
+CORS controls the CORS policy. When nil, all origins, methods, and
+headers are allowed. ErrorMapper and Logger are used for endpoints
+with an ErrorHandlerFunc. Set VersionEndpoint to serve build
+information, including Version, as JSON.
 */
 type SPAConfig struct {
-	AppDirectory  string
-	AppFileSystem embed.FS
-	Endpoints     Endpoints
-	Host          string
-	IdleTimeout   int
-	IndexHTML     []byte
-	MainJS        []byte
-	ManifestJSON  []byte
-	ReadTimeout   int
-	Version       string
-	WriteTimeout  int
+	AppDirectory    string
+	AppFileSystem   embed.FS
+	CORS            *middlewares.CORSConfig
+	Endpoints       Endpoints
+	ErrorMapper     ErrorMapper
+	Host            string
+	IdleTimeout     int
+	Logger          Logger
+	IndexHTML       []byte
+	MainJS          []byte
+	ManifestJSON    []byte
+	ReadTimeout     int
+	Version         string
+	VersionEndpoint *VersionOptions
+	WriteTimeout    int
 }
 
 /*
@@ -59,32 +66,7 @@ some preconfigured defaults for single page applications. The HTTP server
 is setup to use the resulting router.
 */
 func NewSPARouterAndServer(config SPAConfig) (*mux.Router, *http.Server) {
-	router := mux.NewRouter()
-	server := &http.Server{
-		Addr:         config.Host,
-		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
-		Handler:      router,
-	}
-
-	fs := http.FileServer(getClientAppFileSystem(config))
-	router.Use(middlewares.AccessControl(middlewares.AllowAllOrigins, middlewares.AllowAllMethods, middlewares.AllowAllHeaders))
-
-	sort.Sort(config.Endpoints)
-
-	for _, e := range config.Endpoints {
-		if e.HandlerFunc != nil {
-			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
-		} else {
-			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
-		}
-	}
-
-	router.PathPrefix("/static/").Handler(fs).Methods(http.MethodGet)
-	router.HandleFunc(`/{path:[a-zA-Z0-9\-_\/\.]*}`, getRootHandler(config))
-
-	return router, server
+	return NewServer(spaOptions(config)...)
 }
 
 /*
@@ -93,31 +75,35 @@ with some preconfigured defaults for a single page application. The HTTP
 server is setup to use the resulting router.
 */
 func NewSPAServer(router *mux.Router, config SPAConfig) *http.Server {
-	server := &http.Server{
-		Addr:         config.Host,
-		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
-		Handler:      router,
+	_, server := NewServer(append(spaOptions(config), WithRouter(router))...)
+	return server
+}
+
+func spaOptions(config SPAConfig) []Option {
+	opts := []Option{
+		WithHost(config.Host),
+		WithTimeouts(config.IdleTimeout, config.ReadTimeout, config.WriteTimeout),
+		WithEndpoints(config.Endpoints),
+		WithErrorMapper(config.ErrorMapper),
+		WithLogger(config.Logger),
+		WithSPA(config),
 	}
 
-	fs := http.FileServer(getClientAppFileSystem(config))
-	router.Use(middlewares.AccessControl(middlewares.AllowAllOrigins, middlewares.AllowAllMethods, middlewares.AllowAllHeaders))
+	if config.CORS != nil {
+		opts = append(opts, WithCORS(*config.CORS))
+	}
 
-	sort.Sort(config.Endpoints)
+	if config.VersionEndpoint != nil {
+		versionOptions := *config.VersionEndpoint
 
-	for _, e := range config.Endpoints {
-		if e.HandlerFunc != nil {
-			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
-		} else {
-			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
+		if versionOptions.Version == "" {
+			versionOptions.Version = config.Version
 		}
-	}
 
-	router.PathPrefix("/static/").Handler(fs).Methods(http.MethodGet)
-	router.HandleFunc(`/{path:[a-zA-Z0-9\-_\/\.]*}`, getRootHandler(config))
+		opts = append(opts, WithVersion(versionOptions))
+	}
 
-	return server
+	return opts
 }
 
 func getClientAppFileSystem(spaConfig SPAConfig) http.FileSystem {
@@ -151,7 +137,7 @@ func getRootHandler(spaConfig SPAConfig) func(w http.ResponseWriter, r *http.Req
 		}
 
 		if strings.Index(path, ".") > -1 {
-			http.Error(w, "Not found", http.StatusNotFound)
+			WriteProblem(nil, w, NewProblem(http.StatusNotFound, "Not found"))
 			return
 		}
 
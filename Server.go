@@ -0,0 +1,392 @@
+package nerdweb
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/gorilla/mux"
+)
+
+/*
+Option configures a server created by NewServer
+*/
+type Option func(*serverOptions)
+
+type staticDir struct {
+	prefix     string
+	fileSystem http.FileSystem
+}
+
+type serverOptions struct {
+	cors             middlewares.CORSConfig
+	endpoints        Endpoints
+	errorMapper      ErrorMapper
+	health           *Health
+	host             string
+	idleTimeout      int
+	ipResolver       *middlewares.IPResolver
+	logger           Logger
+	metrics          *middlewares.Metrics
+	metricsPath      string
+	middlewares      []mux.MiddlewareFunc
+	readTimeout      int
+	recoverOptions   middlewares.RecoverOptions
+	requestIDOptions middlewares.RequestIDOptions
+	router           *mux.Router
+	spa              *SPAConfig
+	staticDirs       []staticDir
+	tracing          *middlewares.TracingOptions
+	version          *VersionOptions
+	writeTimeout     int
+}
+
+/*
+WithHost sets the address the HTTP server listens on.
+*/
+func WithHost(host string) Option {
+	return func(o *serverOptions) {
+		o.host = host
+	}
+}
+
+/*
+WithRouter uses an existing Gorilla router instead of creating
+a new one.
+*/
+func WithRouter(router *mux.Router) Option {
+	return func(o *serverOptions) {
+		o.router = router
+	}
+}
+
+/*
+WithEndpoints registers a set of endpoints on the router. This option
+may be used more than once, in which case all endpoints are registered.
+*/
+func WithEndpoints(endpoints Endpoints) Option {
+	return func(o *serverOptions) {
+		o.endpoints = append(o.endpoints, endpoints...)
+	}
+}
+
+/*
+WithSPA serves a single page application. Static assets are served
+from "/static/", and every other unmatched path returns the application's
+index.html. SPA routes are registered after all endpoints, so API
+endpoints always take precedence.
+*/
+func WithSPA(config SPAConfig) Option {
+	return func(o *serverOptions) {
+		o.spa = &config
+	}
+}
+
+/*
+WithStaticDir serves files from fileSystem under the path prefix.
+*/
+func WithStaticDir(prefix string, fileSystem http.FileSystem) Option {
+	return func(o *serverOptions) {
+		o.staticDirs = append(o.staticDirs, staticDir{prefix: prefix, fileSystem: fileSystem})
+	}
+}
+
+/*
+WithCORS sets the CORS policy applied to every route. By default all
+origins, methods, and headers are allowed.
+*/
+func WithCORS(config middlewares.CORSConfig) Option {
+	return func(o *serverOptions) {
+		o.cors = config
+	}
+}
+
+/*
+WithErrorMapper sets how errors returned by ErrorHandlerFunc endpoints
+are turned into responses. Defaults to DefaultErrorMapper.
+*/
+func WithErrorMapper(mapper ErrorMapper) Option {
+	return func(o *serverOptions) {
+		if mapper != nil {
+			o.errorMapper = mapper
+		}
+	}
+}
+
+/*
+WithHealth serves the liveness, readiness, and report handlers of
+health at the paths configured in its HealthOptions.
+*/
+func WithHealth(health *Health) Option {
+	return func(o *serverOptions) {
+		o.health = health
+	}
+}
+
+/*
+WithIPResolver sets which proxies are trusted when finding the caller's
+IP address, for RealIP and the server's middlewares. Without it only
+loopback proxies are trusted.
+
+Example:
+
+  resolver, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{
+    TrustedProxies: []string{"10.1.0.0/16"},
+  })
+
+  router, server := nerdweb.NewServer(
+    nerdweb.WithIPResolver(resolver),
+  )
+*/
+func WithIPResolver(resolver *middlewares.IPResolver) Option {
+	return func(o *serverOptions) {
+		o.ipResolver = resolver
+	}
+}
+
+/*
+WithLogger sets the logger that request-scoped loggers are derived from.
+It is also used for recovered panics and errors returned by ErrorHandlerFunc
+endpoints. Defaults to the logrus standard logger. Use NewLogrusLogger,
+NewSlogLogger, or NewNopLogger to adapt other loggers.
+*/
+func WithLogger(logger Logger) Option {
+	return func(o *serverOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+/*
+WithMetrics records request metrics with metrics and serves them in the
+Prometheus text exposition format at path. When metrics is nil a
+collector with default options is created. When path is empty
+"/metrics" is used.
+*/
+func WithMetrics(metrics *middlewares.Metrics, path string) Option {
+	return func(o *serverOptions) {
+		if metrics == nil {
+			metrics = middlewares.NewMetrics(middlewares.MetricsOptions{})
+		}
+
+		if path == "" {
+			path = "/metrics"
+		}
+
+		o.metrics = metrics
+		o.metricsPath = path
+	}
+}
+
+/*
+WithRecoverOptions configures the panic recovery middleware that is
+installed on every server.
+*/
+func WithRecoverOptions(options middlewares.RecoverOptions) Option {
+	return func(o *serverOptions) {
+		o.recoverOptions = options
+	}
+}
+
+/*
+WithRequestIDOptions configures the request ID middleware that is
+installed on every server.
+*/
+func WithRequestIDOptions(options middlewares.RequestIDOptions) Option {
+	return func(o *serverOptions) {
+		o.requestIDOptions = options
+	}
+}
+
+/*
+WithTracing enables W3C Trace Context propagation and exports a span
+for every sampled request. When options.Logger is nil the server's
+logger is used.
+*/
+func WithTracing(options middlewares.TracingOptions) Option {
+	return func(o *serverOptions) {
+		o.tracing = &options
+	}
+}
+
+/*
+WithVersion serves build and version information as JSON. See
+VersionHandler.
+*/
+func WithVersion(options VersionOptions) Option {
+	return func(o *serverOptions) {
+		if options.Path == "" {
+			options.Path = "/version"
+		}
+
+		o.version = &options
+	}
+}
+
+/*
+WithTimeouts sets the idle, read, and write timeouts, in seconds, of
+the HTTP server.
+*/
+func WithTimeouts(idleTimeout, readTimeout, writeTimeout int) Option {
+	return func(o *serverOptions) {
+		o.idleTimeout = idleTimeout
+		o.readTimeout = readTimeout
+		o.writeTimeout = writeTimeout
+	}
+}
+
+/*
+WithMiddleware adds middlewares to the router. They are applied in the
+order given, after the request ID, metrics, request-scoped logger,
+tracing, panic recovery, and CORS middlewares.
+*/
+func WithMiddleware(middlewares ...mux.MiddlewareFunc) Option {
+	return func(o *serverOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+/*
+NewServer creates a Gorilla router and HTTP server composed from the
+provided options. REST endpoints, single page applications, and static
+directories can all be mixed on the same router. Every request is
+given a request ID and a request-scoped logger, and panics in handlers
+are recovered and logged.
+Unless overridden, the HTTP server is configured with an idle timeout
+of 60 seconds, and a read and write timeout of 30 seconds.
+
+Example:
+
+  router, server := nerdweb.NewServer(
+    nerdweb.WithHost("localhost:8080"),
+    nerdweb.WithEndpoints(endpoints),
+    nerdweb.WithSPA(spaConfig),
+  )
+*/
+func NewServer(opts ...Option) (*mux.Router, *http.Server) {
+	o := &serverOptions{
+		cors:         middlewares.AllowAllCORSConfig(),
+		endpoints:    make(Endpoints, 0, 20),
+		errorMapper:  DefaultErrorMapper,
+		idleTimeout:  60,
+		logger:       standardLogger(),
+		readTimeout:  30,
+		writeTimeout: 30,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	router := o.router
+
+	if router == nil {
+		router = mux.NewRouter()
+	}
+
+	server := &http.Server{
+		Addr:         o.host,
+		WriteTimeout: time.Second * time.Duration(o.writeTimeout),
+		ReadTimeout:  time.Second * time.Duration(o.readTimeout),
+		IdleTimeout:  time.Second * time.Duration(o.idleTimeout),
+		Handler:      router,
+	}
+
+	if o.ipResolver != nil {
+		router.Use(middlewares.TrustedProxies(o.ipResolver))
+	}
+
+	router.Use(middlewares.RequestID(o.requestIDOptions))
+
+	if o.metrics != nil {
+		router.Use(o.metrics.Middleware())
+	}
+
+	router.Use(middlewares.RequestScopedLogger(o.logger))
+
+	if o.tracing != nil {
+		if o.tracing.Logger == nil {
+			o.tracing.Logger = o.logger
+		}
+
+		router.Use(middlewares.Tracing(*o.tracing))
+	}
+
+	router.Use(middlewares.Recover(o.logger, o.recoverOptions))
+	cors := middlewares.CORS(o.cors)
+	router.Use(cors)
+	router.Use(o.middlewares...)
+
+	sort.Sort(o.endpoints)
+
+	for _, e := range o.endpoints {
+		switch {
+		case e.HandlerFunc != nil:
+			router.HandleFunc(e.Path, e.HandlerFunc).Methods(e.Methods...)
+		case e.ErrorHandlerFunc != nil:
+			router.HandleFunc(e.Path, HandleErrors(e.ErrorHandlerFunc, o.errorMapper, o.logger)).Methods(e.Methods...)
+		default:
+			router.Handle(e.Path, e.Handler).Methods(e.Methods...)
+		}
+	}
+
+	if o.version != nil {
+		router.HandleFunc(o.version.Path, VersionHandler(*o.version)).Methods(http.MethodGet, http.MethodHead)
+	}
+
+	if o.health != nil {
+		router.HandleFunc(o.health.options.LivenessPath, o.health.LivenessHandler()).Methods(http.MethodGet, http.MethodHead)
+		router.HandleFunc(o.health.options.ReadinessPath, o.health.ReadinessHandler()).Methods(http.MethodGet, http.MethodHead)
+		router.HandleFunc(o.health.options.ReportPath, o.health.ReportHandler()).Methods(http.MethodGet, http.MethodHead)
+	}
+
+	if o.metrics != nil {
+		router.Handle(o.metricsPath, o.metrics.Handler()).Methods(http.MethodGet)
+	}
+
+	for _, d := range o.staticDirs {
+		router.PathPrefix(d.prefix).Handler(http.FileServer(d.fileSystem)).Methods(http.MethodGet)
+	}
+
+	if o.spa != nil {
+		router.PathPrefix("/static/").Handler(http.FileServer(getClientAppFileSystem(*o.spa))).Methods(http.MethodGet)
+		router.HandleFunc(`/{path:[a-zA-Z0-9\-_\/\.]*}`, getRootHandler(*o.spa))
+	}
+
+	/*
+	 * Gorilla Mux only runs middlewares on matched routes, so CORS
+	 * preflight requests for routes without an OPTIONS method would
+	 * never reach it. Run it on unmatched requests too.
+	 */
+	if router.NotFoundHandler == nil {
+		router.NotFoundHandler = http.HandlerFunc(notFound)
+	}
+
+	if router.MethodNotAllowedHandler == nil {
+		router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
+	}
+
+	router.NotFoundHandler = cors(router.NotFoundHandler)
+	router.MethodNotAllowedHandler = cors(router.MethodNotAllowedHandler)
+
+	/*
+	 * Count requests that match no route too, so 404s and 405s show
+	 * up in the metrics.
+	 */
+	if o.metrics != nil {
+		router.NotFoundHandler = o.metrics.Middleware()(router.NotFoundHandler)
+		router.MethodNotAllowedHandler = o.metrics.Middleware()(router.MethodNotAllowedHandler)
+	}
+
+	return router, server
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(nil, w, NewProblem(http.StatusNotFound, "not found"))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(nil, w, NewProblem(http.StatusMethodNotAllowed, "method not allowed"))
+}
@@ -0,0 +1,248 @@
+package nerdweb_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2"
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/gorilla/mux"
+)
+
+func TestNewServer(t *testing.T) {
+	middlewareCalled := false
+
+	endpoints := nerdweb.Endpoints{
+		{Path: "/api/version", Methods: []string{http.MethodGet}, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("version 1"))
+		}},
+	}
+
+	staticFS := fstest.MapFS{
+		"static/app.css": &fstest.MapFile{Data: []byte("body {}")},
+	}
+
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			middlewareCalled = true
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	router, server := nerdweb.NewServer(
+		nerdweb.WithHost("localhost:8080"),
+		nerdweb.WithTimeouts(10, 20, 30),
+		nerdweb.WithEndpoints(endpoints),
+		nerdweb.WithStaticDir("/static/", http.FS(staticFS)),
+		nerdweb.WithMiddleware(middleware),
+	)
+
+	if server.Addr != "localhost:8080" {
+		t.Errorf("wanted address localhost:8080, got %s", server.Addr)
+	}
+
+	if server.IdleTimeout != 10*time.Second || server.ReadTimeout != 20*time.Second || server.WriteTimeout != 30*time.Second {
+		t.Errorf("unexpected timeouts: idle %s, read %s, write %s", server.IdleTimeout, server.ReadTimeout, server.WriteTimeout)
+	}
+
+	if server.Handler != router {
+		t.Errorf("expected server handler to be the router")
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Serves registered endpoints",
+			path:       "/api/version",
+			wantStatus: http.StatusOK,
+			wantBody:   "version 1",
+		},
+		{
+			name:       "Serves files from static directories",
+			path:       "/static/app.css",
+			wantStatus: http.StatusOK,
+			wantBody:   "body {}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middlewareCalled = false
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set("Origin", "https://example.com")
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("wanted body '%s', got '%s'", tt.wantBody, w.Body.String())
+			}
+
+			if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+				t.Errorf("expected CORS headers to be set")
+			}
+
+			if !middlewareCalled {
+				t.Errorf("expected custom middleware to be called")
+			}
+		})
+	}
+}
+
+func TestNewServerPreflight(t *testing.T) {
+	endpoints := nerdweb.Endpoints{
+		{Path: "/api/items", Methods: []string{http.MethodPut}, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {}},
+	}
+
+	router, _ := nerdweb.NewServer(
+		nerdweb.WithEndpoints(endpoints),
+		nerdweb.WithCORS(middlewares.CORSConfig{
+			AllowedOrigins: []string{"https://example.com"},
+			AllowedMethods: []string{http.MethodPut},
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodOptions, "/api/items", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("wanted status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("wanted allowed origin https://example.com, got '%s'", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "Writes a problem for a method that is not allowed", method: http.MethodDelete, path: "/api/items", wantStatus: http.StatusMethodNotAllowed},
+		{name: "Writes a problem for an unknown path", method: http.MethodGet, path: "/api/unknown", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("wanted content type application/problem+json, got '%s'", got)
+			}
+		})
+	}
+}
+
+func TestNewServerOptionsRouteAddedLater(t *testing.T) {
+	router, _ := nerdweb.NewServer()
+
+	router.HandleFunc("/api/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodOptions)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Reaches an OPTIONS route registered after NewServer",
+			path:       "/api/items",
+			wantStatus: http.StatusOK,
+			wantAllow:  "GET, OPTIONS",
+		},
+		{
+			name:       "Returns 404 for unknown paths",
+			path:       "/api/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("wanted Allow '%s', got '%s'", tt.wantAllow, got)
+			}
+		})
+	}
+}
+
+func TestNewServerWithRouter(t *testing.T) {
+	router := mux.NewRouter()
+	gotRouter, server := nerdweb.NewServer(nerdweb.WithRouter(router))
+
+	if gotRouter != router || server.Handler != router {
+		t.Errorf("expected the provided router to be used")
+	}
+}
+
+func TestNewServerWithMetrics(t *testing.T) {
+	router, _ := nerdweb.NewServer(
+		nerdweb.WithMetrics(nil, "/internal/metrics"),
+		nerdweb.WithEndpoints(nerdweb.Endpoints{
+			{Path: "/api/widgets/{id}", Methods: []string{http.MethodGet}, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				panic("widget exploded")
+			}},
+		}),
+		nerdweb.WithLogger(nerdweb.NewNopLogger()),
+	)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/widgets/4", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internal/metrics", nil))
+
+	want := `http_requests_total{method="GET",route="/api/widgets/{id}",status="5xx"} 1`
+
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected '%s' in metrics output:\n%s", want, w.Body.String())
+	}
+}
+
+func TestNewServerWithMetricsCountsUnmatchedRequests(t *testing.T) {
+	router, _ := nerdweb.NewServer(nerdweb.WithMetrics(nil, ""), nerdweb.WithLogger(nerdweb.NewNopLogger()))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/metrics", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="unmatched",status="4xx"} 1`,
+		`http_requests_total{method="POST",route="unmatched",status="4xx"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected '%s' in metrics output:\n%s", want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package nerdweb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrStorageInvalidKey = errors.New("invalid storage key")
+	ErrStorageNotFound   = errors.New("file not found in storage")
+)
+
+/*
+Storage is where uploaded files are kept. Keys are slash separated
+paths, such as "avatars/4f1c.png". Save must read content until it
+returns io.EOF, and must return any error content returns, so size
+limits stop the upload. When Save fails it must leave any existing
+file under key unchanged and clean up what it wrote. Open returns
+ErrStorageNotFound for missing keys. Delete does not fail for
+missing keys.
+*/
+type Storage interface {
+	Delete(ctx context.Context, key string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Save(ctx context.Context, key string, content io.Reader) error
+}
+
+/*
+LocalStorage stores files in a directory on disk. Files are written to
+a temporary file first, and only appear under their key once they are
+complete. Files are saved with mode 0644 and directories with 0755,
+so a separate static file server can read them.
+*/
+type LocalStorage struct {
+	dir string
+}
+
+/*
+NewLocalStorage creates storage that keeps files in dir. dir is
+created when the first file is saved.
+*/
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+/*
+Delete removes the file stored under key.
+*/
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	var (
+		err  error
+		path string
+	)
+
+	if path, err = s.path(key); err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting file %s: %w", key, err)
+	}
+
+	return nil
+}
+
+/*
+Open returns the file stored under key. The caller must close it.
+*/
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	var (
+		err  error
+		path string
+		file *os.File
+	)
+
+	if path, err = s.path(key); err != nil {
+		return nil, err
+	}
+
+	if file, err = os.Open(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrStorageNotFound, key)
+		}
+
+		return nil, fmt.Errorf("error opening file %s: %w", key, err)
+	}
+
+	return file, nil
+}
+
+/*
+Save writes content to the file for key, replacing any existing file.
+*/
+func (s *LocalStorage) Save(ctx context.Context, key string, content io.Reader) error {
+	var (
+		err  error
+		path string
+		temp *os.File
+	)
+
+	if path, err = s.path(key); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", key, err)
+	}
+
+	if temp, err = os.CreateTemp(filepath.Dir(path), ".upload-*"); err != nil {
+		return fmt.Errorf("error creating file for %s: %w", key, err)
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err = io.Copy(temp, content); err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("error writing file %s: %w", key, err)
+	}
+
+	/*
+	 * CreateTemp makes files only the owner can read.
+	 */
+	if err = temp.Chmod(0o644); err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("error setting permissions on file %s: %w", key, err)
+	}
+
+	if err = temp.Close(); err != nil {
+		return fmt.Errorf("error writing file %s: %w", key, err)
+	}
+
+	if err = os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("error saving file %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(key))
+
+	if key == "" || filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrStorageInvalidKey, key)
+	}
+
+	return filepath.Join(s.dir, cleaned), nil
+}
+
+/*
+MemoryStorage keeps files in memory. It is meant for tests and
+development.
+*/
+type MemoryStorage struct {
+	files map[string][]byte
+	lock  sync.RWMutex
+}
+
+/*
+NewMemoryStorage creates empty in-memory storage.
+*/
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: map[string][]byte{}}
+}
+
+/*
+Delete removes the file stored under key.
+*/
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.files, key)
+	return nil
+}
+
+/*
+Keys returns the keys of every stored file.
+*/
+func (s *MemoryStorage) Keys() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := make([]string, 0, len(s.files))
+
+	for key := range s.files {
+		result = append(result, key)
+	}
+
+	return result
+}
+
+/*
+Open returns the file stored under key.
+*/
+func (s *MemoryStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	content, ok := s.files[key]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStorageNotFound, key)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+/*
+Save stores content under key, replacing any existing file.
+*/
+func (s *MemoryStorage) Save(ctx context.Context, key string, content io.Reader) error {
+	if key == "" {
+		return fmt.Errorf("%w: %q", ErrStorageInvalidKey, key)
+	}
+
+	b, err := io.ReadAll(content)
+
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", key, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.files[key] = b
+	return nil
+}
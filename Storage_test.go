@@ -0,0 +1,122 @@
+package nerdweb_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) nerdweb.Storage
+	}{
+		{
+			name: "LocalStorage",
+			storage: func(t *testing.T) nerdweb.Storage {
+				return nerdweb.NewLocalStorage(t.TempDir())
+			},
+		},
+		{
+			name: "MemoryStorage",
+			storage: func(t *testing.T) nerdweb.Storage {
+				return nerdweb.NewMemoryStorage()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := tt.storage(t)
+
+			if err := storage.Save(ctx, "photos/beach.png", strings.NewReader("content")); err != nil {
+				t.Fatalf("wanted no error saving, got %v", err)
+			}
+
+			file, err := storage.Open(ctx, "photos/beach.png")
+
+			if err != nil {
+				t.Fatalf("wanted no error opening, got %v", err)
+			}
+
+			got, _ := io.ReadAll(file)
+			_ = file.Close()
+
+			if string(got) != "content" {
+				t.Errorf("wanted content, got %q", got)
+			}
+
+			if err = storage.Delete(ctx, "photos/beach.png"); err != nil {
+				t.Fatalf("wanted no error deleting, got %v", err)
+			}
+
+			if err = storage.Delete(ctx, "photos/beach.png"); err != nil {
+				t.Errorf("wanted no error deleting a missing file, got %v", err)
+			}
+
+			if _, err = storage.Open(ctx, "photos/beach.png"); !errors.Is(err, nerdweb.ErrStorageNotFound) {
+				t.Errorf("wanted ErrStorageNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLocalStorageRejectsKeysOutsideTheDirectory(t *testing.T) {
+	storage := nerdweb.NewLocalStorage(filepath.Join(t.TempDir(), "uploads"))
+
+	for _, key := range []string{"", "..", "../escape.txt", "photos/../../escape.txt"} {
+		if err := storage.Save(context.Background(), key, strings.NewReader("x")); !errors.Is(err, nerdweb.ErrStorageInvalidKey) {
+			t.Errorf("wanted ErrStorageInvalidKey for %q, got %v", key, err)
+		}
+	}
+}
+
+func TestLocalStorageLeavesNoFileWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	storage := nerdweb.NewLocalStorage(dir)
+
+	reader := io.MultiReader(strings.NewReader("partial"), errorReader{})
+
+	if err := storage.Save(context.Background(), "file.txt", reader); err == nil {
+		t.Fatalf("wanted an error")
+	}
+
+	entries, _ := os.ReadDir(dir)
+
+	if len(entries) != 0 {
+		t.Errorf("wanted an empty directory, got %d entries", len(entries))
+	}
+}
+
+func TestLocalStorageFilesAreReadable(t *testing.T) {
+	dir := t.TempDir()
+	storage := nerdweb.NewLocalStorage(dir)
+
+	if err := storage.Save(context.Background(), "photos/beach.png", strings.NewReader("content")); err != nil {
+		t.Fatalf("wanted no error saving, got %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "photos", "beach.png"))
+
+	if err != nil {
+		t.Fatalf("wanted the file to exist, got %v", err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o644 {
+		t.Errorf("wanted mode 0644, got %o", info.Mode().Perm())
+	}
+}
+
+type errorReader struct{}
+
+func (errorReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
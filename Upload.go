@@ -0,0 +1,390 @@
+package nerdweb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultMaxUploadFileSize  int64 = 10 << 20
+	defaultMaxUploadFiles           = 10
+	defaultMaxUploadTotalSize int64 = 32 << 20
+
+	sniffLength = 512
+
+	// multipartOverhead is allowed on top of MaxTotalSize for part
+	// boundaries and headers
+	multipartOverhead int64 = 1 << 20
+)
+
+var (
+	ErrUploadContentType  = errors.New("file type is not allowed")
+	ErrUploadFileTooLarge = errors.New("file is too large")
+	ErrUploadInvalid      = errors.New("upload is not a valid multipart form")
+	ErrUploadTooLarge     = errors.New("upload is too large")
+	ErrUploadTooManyFiles = errors.New("upload has too many files")
+)
+
+/*
+UploadOptions configures Upload.
+
+Storage is where files are saved, and is required. AllowedContentTypes
+lists the media types files may have, such as "image/png", or "image/*"
+for any image. When empty every type is allowed. MaxFileSize limits
+each file and defaults to 10MB. MaxTotalSize limits all files and
+fields together and defaults to 32MB. Use a negative value for either
+for no limit. MaxFiles defaults to 10; use a negative value for no
+limit. KeyFunc returns the storage key for a file, and defaults to a
+random name with the file's extension.
+*/
+type UploadOptions struct {
+	AllowedContentTypes []string
+	KeyFunc             func(field, filename string) string
+	MaxFileSize         int64
+	MaxFiles            int
+	MaxTotalSize        int64
+	Storage             Storage
+}
+
+/*
+UploadedFile describes a stored file. ContentType is detected from
+the file's content, not taken from the request. Checksum is the hex
+encoded SHA-256 of the content.
+*/
+type UploadedFile struct {
+	Checksum    string `json:"checksum"`
+	ContentType string `json:"contentType"`
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+}
+
+/*
+UploadResult is the outcome of Upload. Fields holds the form values
+that are not files.
+*/
+type UploadResult struct {
+	Fields url.Values
+	Files  []UploadedFile
+}
+
+/*
+Upload reads a multipart/form-data request and streams each file to
+storage as it arrives, without buffering whole files in memory or on
+disk. The content type of each file is detected from its first 512
+bytes and checked against the allowed types, and a checksum is
+computed while it is saved.
+
+When anything fails, the files already saved by this upload are
+deleted. A file that fails while being saved is left to the Storage
+to clean up, so an existing file under the same key is kept. Problems
+with the request are returned as an *HTTPError wrapping one of these
+errors:
+
+  - ErrUploadInvalid: 400, code "invalid_upload", also used for
+    requests that are not multipart/form-data
+  - ErrUploadContentType: 415, code "file_type_not_allowed"
+  - ErrUploadFileTooLarge: 413, code "file_too_large"
+  - ErrUploadTooLarge: 413, code "upload_too_large"
+  - ErrUploadTooManyFiles: 400, code "too_many_files"
+
+Storage failures are returned as other errors. w is used to tell the
+server to close the connection when the upload is too large, and may
+be nil.
+
+Example:
+
+  storage := nerdweb.NewLocalStorage("/var/uploads")
+
+  func uploadAvatar(w http.ResponseWriter, r *http.Request) error {
+    result, err := nerdweb.Upload(w, r, nerdweb.UploadOptions{
+      AllowedContentTypes: []string{"image/png", "image/jpeg"},
+      MaxFileSize:         2 << 20,
+      MaxFiles:            1,
+      Storage:             storage,
+    })
+
+    if err != nil {
+      return err
+    }
+
+    nerdweb.WriteJSON(nil, w, http.StatusCreated, result.Files)
+    return nil
+  }
+*/
+func Upload(w http.ResponseWriter, r *http.Request, options UploadOptions) (UploadResult, error) {
+	var (
+		err    error
+		reader *multipart.Reader
+		result = UploadResult{Fields: url.Values{}}
+	)
+
+	if options.Storage == nil {
+		return result, errors.New("upload storage is required")
+	}
+
+	options = uploadDefaults(options)
+
+	if options.MaxTotalSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, options.MaxTotalSize+multipartOverhead)
+	}
+
+	if reader, err = r.MultipartReader(); err != nil {
+		return result, newHTTPErrorWithDetails(http.StatusBadRequest, "invalid_upload", "request must be multipart/form-data", nil, ErrUploadInvalid, err)
+	}
+
+	upload := &uploader{
+		ctx:     r.Context(),
+		options: options,
+		result:  &result,
+	}
+
+	if err = upload.readParts(reader); err != nil {
+		upload.deleteFiles()
+		return UploadResult{Fields: url.Values{}}, err
+	}
+
+	return result, nil
+}
+
+type uploader struct {
+	ctx       context.Context
+	options   UploadOptions
+	result    *UploadResult
+	totalRead int64
+}
+
+func (u *uploader) readParts(reader *multipart.Reader) error {
+	for {
+		part, err := reader.NextPart()
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return u.requestError(err)
+		}
+
+		if part.FileName() == "" {
+			err = u.readField(part)
+		} else {
+			err = u.saveFile(part)
+		}
+
+		_ = part.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (u *uploader) readField(part *multipart.Part) error {
+	limiter := &uploadLimiter{fileLimit: -1, reader: part, uploader: u}
+	value, err := io.ReadAll(limiter)
+
+	if err != nil {
+		if limiter.err != nil {
+			return limiter.err
+		}
+
+		return u.requestError(err)
+	}
+
+	u.result.Fields.Add(part.FormName(), string(value))
+	return nil
+}
+
+func (u *uploader) saveFile(part *multipart.Part) error {
+	filename := filepath.Base(part.FileName())
+
+	if u.options.MaxFiles > 0 && len(u.result.Files) >= u.options.MaxFiles {
+		return newHTTPErrorWithDetails(http.StatusBadRequest, "too_many_files",
+			fmt.Sprintf("upload must not have more than %d files", u.options.MaxFiles),
+			map[string]interface{}{"limit": u.options.MaxFiles}, ErrUploadTooManyFiles, nil)
+	}
+
+	sniffed := make([]byte, sniffLength)
+	n, err := io.ReadFull(part, sniffed)
+
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return u.requestError(err)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniffed[:n]))
+
+	if !contentTypeAllowed(contentType, u.options.AllowedContentTypes) {
+		return newHTTPErrorWithDetails(http.StatusUnsupportedMediaType, "file_type_not_allowed",
+			fmt.Sprintf("file %q has type %s, which is not allowed", filename, contentType),
+			map[string]interface{}{"contentType": contentType, "field": part.FormName(), "filename": filename}, ErrUploadContentType, nil)
+	}
+
+	checksum := sha256.New()
+
+	limiter := &uploadLimiter{
+		field:     part.FormName(),
+		fileLimit: u.options.MaxFileSize,
+		filename:  filename,
+		hash:      checksum,
+		reader:    io.MultiReader(bytes.NewReader(sniffed[:n]), part),
+		uploader:  u,
+	}
+
+	file := UploadedFile{
+		ContentType: contentType,
+		Field:       part.FormName(),
+		Filename:    filename,
+		Key:         u.options.KeyFunc(part.FormName(), filename),
+	}
+
+	if err = u.options.Storage.Save(u.ctx, file.Key, limiter); err != nil {
+		if limiter.err != nil {
+			return limiter.err
+		}
+
+		if limiter.readErr != nil {
+			return u.requestError(limiter.readErr)
+		}
+
+		return fmt.Errorf("error saving uploaded file %q: %w", filename, err)
+	}
+
+	file.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	file.Size = limiter.read
+
+	u.result.Files = append(u.result.Files, file)
+	return nil
+}
+
+func (u *uploader) deleteFiles() {
+	for _, file := range u.result.Files {
+		_ = u.options.Storage.Delete(u.ctx, file.Key)
+	}
+}
+
+func (u *uploader) totalTooLarge() error {
+	return newHTTPErrorWithDetails(http.StatusRequestEntityTooLarge, "upload_too_large",
+		fmt.Sprintf("upload must not be larger than %d bytes", u.options.MaxTotalSize),
+		map[string]interface{}{"limit": u.options.MaxTotalSize}, ErrUploadTooLarge, nil)
+}
+
+func (u *uploader) requestError(err error) error {
+	var maxBytesError *http.MaxBytesError
+
+	if errors.As(err, &maxBytesError) {
+		return u.totalTooLarge()
+	}
+
+	return newHTTPErrorWithDetails(http.StatusBadRequest, "invalid_upload", "request is not a valid multipart form", nil, ErrUploadInvalid, err)
+}
+
+/*
+uploadLimiter counts the bytes of a part as they are read, feeding
+them to the checksum, and fails the read once a limit is passed.
+*/
+type uploadLimiter struct {
+	err       error
+	field     string
+	fileLimit int64
+	filename  string
+	hash      hash.Hash
+	read      int64
+	readErr   error
+	reader    io.Reader
+	uploader  *uploader
+}
+
+func (l *uploadLimiter) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+	l.uploader.totalRead += int64(n)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		l.readErr = err
+	}
+
+	switch {
+	case l.fileLimit > 0 && l.read > l.fileLimit:
+		l.err = newHTTPErrorWithDetails(http.StatusRequestEntityTooLarge, "file_too_large",
+			fmt.Sprintf("file %q must not be larger than %d bytes", l.filename, l.fileLimit),
+			map[string]interface{}{"field": l.field, "filename": l.filename, "limit": l.fileLimit}, ErrUploadFileTooLarge, nil)
+
+		return 0, l.err
+
+	case l.uploader.options.MaxTotalSize > 0 && l.uploader.totalRead > l.uploader.options.MaxTotalSize:
+		l.err = l.uploader.totalTooLarge()
+		return 0, l.err
+	}
+
+	if l.hash != nil {
+		_, _ = l.hash.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func uploadDefaults(options UploadOptions) UploadOptions {
+	if options.MaxFileSize == 0 {
+		options.MaxFileSize = defaultMaxUploadFileSize
+	}
+
+	if options.MaxFiles == 0 {
+		options.MaxFiles = defaultMaxUploadFiles
+	}
+
+	if options.MaxTotalSize == 0 {
+		options.MaxTotalSize = defaultMaxUploadTotalSize
+	}
+
+	if options.KeyFunc == nil {
+		options.KeyFunc = randomUploadKey
+	}
+
+	return options
+}
+
+func randomUploadKey(field, filename string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b) + strings.ToLower(filepath.Ext(filename))
+}
+
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+		if pattern == contentType || pattern == "*/*" {
+			return true
+		}
+
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,300 @@
+package nerdweb_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+type uploadPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func newUploadRequest(t *testing.T, parts []uploadPart) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, part := range parts {
+		if part.filename == "" {
+			_ = writer.WriteField(part.field, string(part.content))
+			continue
+		}
+
+		w, err := writer.CreateFormFile(part.field, part.filename)
+
+		if err != nil {
+			t.Fatalf("error creating form file: %v", err)
+		}
+
+		_, _ = w.Write(part.content)
+	}
+
+	_ = writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+func TestUpload(t *testing.T) {
+	png := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 100)...)
+	text := []byte("hello world")
+
+	tests := []struct {
+		name       string
+		parts      []uploadPart
+		options    nerdweb.UploadOptions
+		wantErr    error
+		wantStatus int
+		wantFiles  int
+	}{
+		{
+			name: "Stores files and reads fields",
+			parts: []uploadPart{
+				{field: "title", content: []byte("Holiday")},
+				{field: "photo", filename: "beach.PNG", content: png},
+				{field: "notes", filename: "notes.txt", content: text},
+			},
+			wantFiles: 2,
+		},
+		{
+			name: "Allows content types by wildcard",
+			parts: []uploadPart{
+				{field: "photo", filename: "beach.png", content: png},
+			},
+			options:   nerdweb.UploadOptions{AllowedContentTypes: []string{"image/*"}},
+			wantFiles: 1,
+		},
+		{
+			name: "Rejects content types that are not allowed, using the sniffed type",
+			parts: []uploadPart{
+				{field: "photo", filename: "beach.png", content: png},
+				{field: "photo", filename: "fake.png", content: text},
+			},
+			options:    nerdweb.UploadOptions{AllowedContentTypes: []string{"image/png"}},
+			wantErr:    nerdweb.ErrUploadContentType,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "Rejects files over the file size limit",
+			parts: []uploadPart{
+				{field: "photo", filename: "beach.png", content: png},
+				{field: "big", filename: "big.bin", content: bytes.Repeat([]byte("a"), 2000)},
+			},
+			options:    nerdweb.UploadOptions{MaxFileSize: 1000},
+			wantErr:    nerdweb.ErrUploadFileTooLarge,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Rejects uploads over the total size limit",
+			parts: []uploadPart{
+				{field: "one", filename: "one.txt", content: bytes.Repeat([]byte("a"), 600)},
+				{field: "two", filename: "two.txt", content: bytes.Repeat([]byte("b"), 600)},
+			},
+			options:    nerdweb.UploadOptions{MaxTotalSize: 1000},
+			wantErr:    nerdweb.ErrUploadTooLarge,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Rejects too many files",
+			parts: []uploadPart{
+				{field: "one", filename: "one.txt", content: text},
+				{field: "two", filename: "two.txt", content: text},
+			},
+			options:    nerdweb.UploadOptions{MaxFiles: 1},
+			wantErr:    nerdweb.ErrUploadTooManyFiles,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := nerdweb.NewMemoryStorage()
+			tt.options.Storage = storage
+
+			result, err := nerdweb.Upload(httptest.NewRecorder(), newUploadRequest(t, tt.parts), tt.options)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("wanted error %v, got %v", tt.wantErr, err)
+				}
+
+				var httpError *nerdweb.HTTPError
+
+				if !errors.As(err, &httpError) || httpError.Status != tt.wantStatus {
+					t.Errorf("wanted an HTTPError with status %d, got %v", tt.wantStatus, err)
+				}
+
+				if keys := storage.Keys(); len(keys) != 0 {
+					t.Errorf("wanted stored files to be deleted, got %v", keys)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+
+			if len(result.Files) != tt.wantFiles {
+				t.Fatalf("wanted %d files, got %d", tt.wantFiles, len(result.Files))
+			}
+
+			for index, file := range result.Files {
+				part := tt.parts[len(tt.parts)-len(result.Files)+index]
+				sum := sha256.Sum256(part.content)
+
+				if file.Checksum != hex.EncodeToString(sum[:]) {
+					t.Errorf("wanted checksum of %s to match", file.Filename)
+				}
+
+				if file.Size != int64(len(part.content)) {
+					t.Errorf("wanted size %d, got %d", len(part.content), file.Size)
+				}
+
+				stored, err := storage.Open(context.Background(), file.Key)
+
+				if err != nil {
+					t.Fatalf("wanted %s to be stored, got %v", file.Key, err)
+				}
+
+				got, _ := io.ReadAll(stored)
+
+				if !bytes.Equal(got, part.content) {
+					t.Errorf("wanted stored content of %s to match", file.Filename)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadMetadata(t *testing.T) {
+	png := append(append([]byte{}, pngHeader...), 1, 2, 3)
+
+	r := newUploadRequest(t, []uploadPart{
+		{field: "title", content: []byte("Holiday")},
+		{field: "photo", filename: "beach.PNG", content: png},
+	})
+
+	result, err := nerdweb.Upload(nil, r, nerdweb.UploadOptions{
+		KeyFunc: func(field, filename string) string {
+			return "photos/" + field + "/" + filename
+		},
+		Storage: nerdweb.NewMemoryStorage(),
+	})
+
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+
+	if got := result.Fields.Get("title"); got != "Holiday" {
+		t.Errorf("wanted title field Holiday, got %q", got)
+	}
+
+	file := result.Files[0]
+
+	if file.ContentType != "image/png" || file.Field != "photo" || file.Filename != "beach.PNG" || file.Key != "photos/photo/beach.PNG" {
+		t.Errorf("unexpected metadata %+v", file)
+	}
+}
+
+func TestUploadDefaultKeys(t *testing.T) {
+	r := newUploadRequest(t, []uploadPart{{field: "photo", filename: "beach.PNG", content: pngHeader}})
+	result, err := nerdweb.Upload(nil, r, nerdweb.UploadOptions{Storage: nerdweb.NewMemoryStorage()})
+
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+
+	if key := result.Files[0].Key; len(key) != 36 || !strings.HasSuffix(key, ".png") {
+		t.Errorf("wanted a random key with a .png extension, got %q", key)
+	}
+}
+
+func TestUploadRejectsNonMultipartRequests(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	_, err := nerdweb.Upload(nil, r, nerdweb.UploadOptions{Storage: nerdweb.NewMemoryStorage()})
+
+	if !errors.Is(err, nerdweb.ErrUploadInvalid) {
+		t.Errorf("wanted ErrUploadInvalid, got %v", err)
+	}
+}
+
+func TestUploadKeepsExistingFileWhenSaveFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) nerdweb.Storage
+	}{
+		{
+			name: "LocalStorage",
+			storage: func(t *testing.T) nerdweb.Storage {
+				return nerdweb.NewLocalStorage(t.TempDir())
+			},
+		},
+		{
+			name: "MemoryStorage",
+			storage: func(t *testing.T) nerdweb.Storage {
+				return nerdweb.NewMemoryStorage()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := tt.storage(t)
+			original := append(append([]byte{}, pngHeader...), "original"...)
+
+			if err := storage.Save(ctx, "avatars/42.png", bytes.NewReader(original)); err != nil {
+				t.Fatalf("wanted no error saving, got %v", err)
+			}
+
+			r := newUploadRequest(t, []uploadPart{
+				{field: "avatar", filename: "avatar.png", content: append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 2000)...)},
+			})
+
+			_, err := nerdweb.Upload(nil, r, nerdweb.UploadOptions{
+				KeyFunc: func(field, filename string) string {
+					return "avatars/42.png"
+				},
+				MaxFileSize: 1000,
+				Storage:     storage,
+			})
+
+			if !errors.Is(err, nerdweb.ErrUploadFileTooLarge) {
+				t.Fatalf("wanted ErrUploadFileTooLarge, got %v", err)
+			}
+
+			stored, err := storage.Open(ctx, "avatars/42.png")
+
+			if err != nil {
+				t.Fatalf("wanted the existing file to be kept, got %v", err)
+			}
+
+			got, _ := io.ReadAll(stored)
+			_ = stored.Close()
+
+			if !bytes.Equal(got, original) {
+				t.Errorf("wanted the existing content to be kept, got %q", got)
+			}
+		})
+	}
+}
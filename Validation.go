@@ -0,0 +1,461 @@
+package nerdweb
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	validationPatterns sync.Map
+)
+
+/*
+Validator is implemented by types that need checks struct tags cannot
+express, such as comparing two fields. Validate calls it after the
+tag rules for the struct have run. Return ValidationErrors to report
+field errors; field paths are relative to the struct. Any other error
+stops validation and is returned as is.
+*/
+type Validator interface {
+	Validate() error
+}
+
+/*
+FieldError describes one field that failed validation. Field is the
+path to the field using JSON names, such as "address.city" or
+"items[2].name". Rule is the rule that failed, and Param is its
+parameter, if it has one.
+*/
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Rule    string `json:"rule"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+/*
+ValidationErrors is the list of fields that failed validation.
+DefaultErrorMapper maps it to a 422 Unprocessable Entity problem
+with the code "validation_failed" and the list as details.
+*/
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Message)
+	}
+
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+/*
+Validate checks a struct, or pointer to a struct, against the rules
+in its "validate" struct tags, then calls Validate on any value that
+implements Validator. Nested structs, including embedded unexported
+ones, are always checked. It returns ValidationErrors listing every
+failed field, nil when the value is valid, or another error when a
+tag is malformed.
+
+Rules are separated by commas:
+
+  - required: the value must not be the zero value, or empty
+  - omitempty: skip the remaining rules when the value is empty
+  - min=n, max=n, len=n: the length of strings (in characters),
+    slices, and maps, or the value of numbers
+  - oneof=a b c: the value must be one of the space separated values
+  - email, url, uuid: the string must have the given format
+  - regexp=pattern: the string must match pattern, which cannot
+    contain commas
+  - dive: the rules after dive apply to each element of a slice,
+    array, or map. Struct elements are only checked with dive.
+
+Example:
+
+  type CreateUser struct {
+    Email string   `json:"email" validate:"required,email"`
+    Name  string   `json:"name" validate:"required,max=100"`
+    Role  string   `json:"role" validate:"oneof=admin member"`
+    Tags  []string `json:"tags" validate:"max=5,dive,min=1,max=20"`
+  }
+
+  if err := nerdweb.Validate(&user); err != nil {
+    return err
+  }
+*/
+func Validate(value interface{}) error {
+	v := reflect.ValueOf(value)
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate a %s", v.Kind())
+	}
+
+	result := ValidationErrors{}
+
+	if err := validateStruct(v, "", &result); err != nil {
+		return err
+	}
+
+	if len(result) > 0 {
+		return result
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, path string, result *ValidationErrors) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name := fieldName(field)
+
+		if name == "" {
+			continue
+		}
+
+		fieldPath := joinPath(path, name)
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			fieldPath = path
+		}
+
+		rules := splitRules(field.Tag.Get("validate"))
+
+		if err := validateValue(v.Field(i), fieldPath, rules, result); err != nil {
+			return err
+		}
+	}
+
+	return callValidator(v, path, result)
+}
+
+func validateValue(v reflect.Value, path string, rules []string, result *ValidationErrors) error {
+	for index, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "omitempty":
+			if isEmpty(v) {
+				return nil
+			}
+
+			continue
+
+		case "required":
+			if isEmpty(v) {
+				result.add(path, name, param, "%s is required", path)
+				return nil
+			}
+
+			continue
+
+		case "dive":
+			if err := checkDive(indirect(v)); err != nil {
+				return fmt.Errorf("invalid validation rule %q on %s: %w", rule, path, err)
+			}
+
+			return validateElements(indirect(v), path, rules[index+1:], result)
+		}
+
+		current := indirect(v)
+
+		if !current.IsValid() {
+			return nil
+		}
+
+		ok, message, err := checkRule(current, name, param)
+
+		if err != nil {
+			return fmt.Errorf("invalid validation rule %q on %s: %w", rule, path, err)
+		}
+
+		if !ok {
+			result.add(path, name, param, "%s %s", path, message)
+			return nil
+		}
+	}
+
+	current := indirect(v)
+
+	if current.Kind() == reflect.Struct {
+		return validateStruct(current, path, result)
+	}
+
+	return nil
+}
+
+func validateElements(v reflect.Value, path string, rules []string, result *ValidationErrors) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), rules, result); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		iterator := v.MapRange()
+
+		for iterator.Next() {
+			element := reflect.New(iterator.Value().Type()).Elem()
+			element.Set(iterator.Value())
+
+			if err := validateValue(element, fmt.Sprintf("%s[%v]", path, iterator.Key()), rules, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkDive(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Invalid:
+		return nil
+	default:
+		return fmt.Errorf("dive cannot be used on a %s", v.Kind())
+	}
+}
+
+func checkRule(v reflect.Value, name, param string) (bool, string, error) {
+	switch name {
+	case "min", "max", "len":
+		return checkSize(v, name, param)
+
+	case "oneof":
+		value := fmt.Sprintf("%v", v.Interface())
+
+		for _, allowed := range strings.Fields(param) {
+			if value == allowed {
+				return true, "", nil
+			}
+		}
+
+		return false, "must be one of " + strings.Join(strings.Fields(param), ", "), nil
+	}
+
+	switch name {
+	case "email", "url", "uuid", "regexp":
+	default:
+		return false, "", fmt.Errorf("unknown rule %q", name)
+	}
+
+	if v.Kind() != reflect.String {
+		return false, "", fmt.Errorf("%s can only be used on strings", name)
+	}
+
+	s := v.String()
+
+	switch name {
+	case "email":
+		address, err := mail.ParseAddress(s)
+		return err == nil && address.Address == s, "must be a valid email address", nil
+
+	case "url":
+		parsed, err := url.Parse(s)
+		return err == nil && parsed.Scheme != "" && parsed.Host != "", "must be a valid URL", nil
+
+	case "regexp":
+		pattern, err := compileValidationPattern(param)
+
+		if err != nil {
+			return false, "", err
+		}
+
+		return pattern.MatchString(s), "has an invalid format", nil
+
+	default:
+		return uuidPattern.MatchString(s), "must be a valid UUID", nil
+	}
+}
+
+func checkSize(v reflect.Value, name, param string) (bool, string, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+
+	if err != nil {
+		return false, "", fmt.Errorf("%s needs a number, got %q", name, param)
+	}
+
+	var (
+		size float64
+		unit string
+	)
+
+	switch v.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(v.String()))
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = float64(v.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	default:
+		return false, "", fmt.Errorf("%s cannot be used on a %s", name, v.Kind())
+	}
+
+	switch name {
+	case "min":
+		return size >= limit, "must be at least " + param + unit, nil
+	case "max":
+		return size <= limit, "must be at most " + param + unit, nil
+	default:
+		if unit == "" {
+			return size == limit, "must be " + param, nil
+		}
+
+		return size == limit, "must be exactly " + param + unit, nil
+	}
+}
+
+func callValidator(v reflect.Value, path string, result *ValidationErrors) error {
+	var validator Validator
+
+	if !v.CanInterface() {
+		return nil
+	}
+
+	if v.CanAddr() {
+		validator, _ = v.Addr().Interface().(Validator)
+	}
+
+	if validator == nil {
+		validator, _ = v.Interface().(Validator)
+	}
+
+	if validator == nil {
+		return nil
+	}
+
+	err := validator.Validate()
+
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors ValidationErrors
+
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	for _, fieldError := range fieldErrors {
+		fieldError.Field = joinPath(path, fieldError.Field)
+		*result = append(*result, fieldError)
+	}
+
+	return nil
+}
+
+func (e *ValidationErrors) add(path, rule, param, format string, args ...interface{}) {
+	*e = append(*e, FieldError{
+		Field:   path,
+		Message: fmt.Sprintf(format, args...),
+		Param:   param,
+		Rule:    rule,
+	})
+}
+
+func compileValidationPattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := validationPatterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp %q: %w", pattern, err)
+	}
+
+	validationPatterns.Store(pattern, compiled)
+	return compiled, nil
+}
+
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
+
+func joinPath(path, name string) string {
+	switch {
+	case path == "":
+		return name
+	case name == "":
+		return path
+	default:
+		return path + "." + name
+	}
+}
+
+func splitRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+
+	return strings.Split(tag, ",")
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+
+		v = v.Elem()
+	}
+
+	return v
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Invalid:
+		return true
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,268 @@
+package nerdweb_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+type validationAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"omitempty,regexp=^[0-9]{5}$"`
+}
+
+type validationItem struct {
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+	SKU      string `json:"sku" validate:"len=6"`
+}
+
+type validationOrder struct {
+	Address  validationAddress  `json:"address"`
+	Billing  *validationAddress `json:"billing"`
+	Email    string             `json:"email" validate:"required,email"`
+	ID       string             `json:"id" validate:"uuid"`
+	Items    []validationItem   `json:"items" validate:"required,dive"`
+	Notes    *string            `json:"notes" validate:"omitempty,max=5"`
+	Priority string             `json:"priority" validate:"oneof=low normal high"`
+	Tags     []string           `json:"tags" validate:"max=2,dive,min=2"`
+	Website  string             `json:"website" validate:"omitempty,url"`
+	internal string             `validate:"required"`
+}
+
+type validationRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func (r validationRange) Validate() error {
+	if r.To < r.From {
+		return nerdweb.ValidationErrors{{Field: "to", Message: "to must not be before from", Rule: "range"}}
+	}
+
+	return nil
+}
+
+type validationBooking struct {
+	Dates validationRange `json:"dates"`
+	Name  string          `json:"name" validate:"required"`
+}
+
+func validOrder() validationOrder {
+	return validationOrder{
+		Address:  validationAddress{City: "Springfield", Zip: "12345"},
+		Email:    "adam@example.com",
+		ID:       "0f8fad5b-d9cb-469f-a165-70867728950e",
+		Items:    []validationItem{{Quantity: 1, SKU: "ABC123"}},
+		Priority: "normal",
+		Tags:     []string{"ab"},
+		Website:  "https://example.com",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	longNotes := "too long"
+
+	tests := []struct {
+		name   string
+		modify func(order *validationOrder)
+		want   []string
+	}{
+		{
+			name:   "Returns nil for a valid value",
+			modify: func(order *validationOrder) {},
+			want:   nil,
+		},
+		{
+			name: "Reports required and format failures",
+			modify: func(order *validationOrder) {
+				order.Email = ""
+				order.ID = "nope"
+				order.Website = "example.com"
+			},
+			want: []string{"email:required", "id:uuid", "website:url"},
+		},
+		{
+			name: "Reports invalid email addresses",
+			modify: func(order *validationOrder) {
+				order.Email = "Adam <adam@example.com>"
+			},
+			want: []string{"email:email"},
+		},
+		{
+			name: "Checks oneof and pointer values",
+			modify: func(order *validationOrder) {
+				order.Priority = "urgent"
+				order.Notes = &longNotes
+			},
+			want: []string{"notes:max", "priority:oneof"},
+		},
+		{
+			name: "Checks nested structs using JSON field paths",
+			modify: func(order *validationOrder) {
+				order.Address = validationAddress{Zip: "1234"}
+				order.Billing = &validationAddress{City: "Shelbyville", Zip: "abcde"}
+			},
+			want: []string{"address.city:required", "address.zip:regexp", "billing.zip:regexp"},
+		},
+		{
+			name: "Dives into slices",
+			modify: func(order *validationOrder) {
+				order.Items = []validationItem{{Quantity: 1, SKU: "ABC123"}, {Quantity: 11, SKU: "ABC"}}
+				order.Tags = []string{"ok", "x"}
+			},
+			want: []string{"items[1].quantity:max", "items[1].sku:len", "tags[1]:min"},
+		},
+		{
+			name: "Checks the slice itself before diving",
+			modify: func(order *validationOrder) {
+				order.Items = nil
+				order.Tags = []string{"aa", "bb", "cc"}
+			},
+			want: []string{"items:required", "tags:max"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+
+			got := nerdweb.Validate(&order)
+
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("wanted no error, got %v", got)
+				}
+
+				return
+			}
+
+			var validationErrors nerdweb.ValidationErrors
+
+			if !errors.As(got, &validationErrors) {
+				t.Fatalf("wanted ValidationErrors, got %v", got)
+			}
+
+			gotFields := []string{}
+
+			for _, fieldError := range validationErrors {
+				gotFields = append(gotFields, fieldError.Field+":"+fieldError.Rule)
+			}
+
+			if !reflect.DeepEqual(gotFields, tt.want) {
+				t.Errorf("wanted %v, got %v", tt.want, gotFields)
+			}
+		})
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	order := validOrder()
+	order.Address.City = ""
+	order.Items[0].Quantity = 0
+
+	got := nerdweb.Validate(order)
+
+	want := nerdweb.ValidationErrors{
+		{Field: "address.city", Message: "address.city is required", Rule: "required"},
+		{Field: "items[0].quantity", Message: "items[0].quantity must be at least 1", Param: "1", Rule: "min"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %#v, got %#v", want, got)
+	}
+}
+
+func TestValidateCallsValidator(t *testing.T) {
+	got := nerdweb.Validate(&validationBooking{Dates: validationRange{From: 5, To: 1}, Name: "Adam"})
+
+	want := nerdweb.ValidationErrors{
+		{Field: "dates.to", Message: "to must not be before from", Rule: "range"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %#v, got %#v", want, got)
+	}
+}
+
+func TestValidateRejectsBadRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{
+			name: "Unknown rule",
+			value: &struct {
+				Name string `validate:"shiny"`
+			}{},
+		},
+		{
+			name: "String rule on a number",
+			value: &struct {
+				Age int `validate:"email"`
+			}{},
+		},
+		{
+			name: "Size rule without a number",
+			value: &struct {
+				Name string `validate:"min=abc"`
+			}{Name: "Adam"},
+		},
+		{
+			name:  "Not a struct",
+			value: "Adam",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nerdweb.Validate(tt.value)
+
+			var validationErrors nerdweb.ValidationErrors
+
+			if got == nil || errors.As(got, &validationErrors) {
+				t.Errorf("wanted a rule error, got %v", got)
+			}
+		})
+	}
+}
+
+func TestReadJSONBodyWithOptionsValidates(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"dates":{"from":1,"to":2}}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	dest := validationBooking{}
+	got := nerdweb.ReadJSONBodyWithOptions(nil, r, &dest, nerdweb.StrictJSONBodyOptions())
+
+	var validationErrors nerdweb.ValidationErrors
+
+	if !errors.As(got, &validationErrors) || len(validationErrors) != 1 || validationErrors[0].Field != "name" {
+		t.Errorf("wanted a required error for name, got %v", got)
+	}
+}
+
+type validationPaging struct {
+	Page int `json:"page" validate:"min=1"`
+}
+
+func TestValidateEmbeddedStructs(t *testing.T) {
+	value := struct {
+		validationPaging
+		Name string `json:"name" validate:"required"`
+	}{Name: "Adam"}
+
+	got := nerdweb.Validate(&value)
+
+	want := nerdweb.ValidationErrors{
+		{Field: "page", Message: "page must be at least 1", Param: "1", Rule: "min"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %#v, got %#v", want, got)
+	}
+}
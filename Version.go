@@ -0,0 +1,141 @@
+package nerdweb
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var processStartTime = time.Now()
+
+/*
+VersionOptions configures the version endpoint.
+
+Version is the application version to report. Path defaults to
+"/version". Set HideDependencies to leave the module dependency list
+out of the response, for example in production.
+*/
+type VersionOptions struct {
+	HideDependencies bool
+	Path             string
+	Version          string
+}
+
+/*
+VCSInfo describes the version control state the binary was built from.
+*/
+type VCSInfo struct {
+	Modified bool   `json:"modified"`
+	Revision string `json:"revision,omitempty"`
+	System   string `json:"system,omitempty"`
+	Time     string `json:"time,omitempty"`
+}
+
+/*
+DependencyInfo is a module the binary was built with.
+*/
+type DependencyInfo struct {
+	Path    string `json:"path"`
+	Replace string `json:"replace,omitempty"`
+	Version string `json:"version"`
+}
+
+/*
+BuildInfo describes the running binary and process.
+*/
+type BuildInfo struct {
+	Dependencies []DependencyInfo `json:"dependencies,omitempty"`
+	GoVersion    string           `json:"goVersion"`
+	Module       string           `json:"module,omitempty"`
+	StartTime    time.Time        `json:"startTime"`
+	Uptime       string           `json:"uptime"`
+	VCS          *VCSInfo         `json:"vcs,omitempty"`
+	Version      string           `json:"version,omitempty"`
+}
+
+/*
+GetBuildInfo returns the application version along with the Go
+version, version control details, and module dependencies recorded
+in the binary by the Go toolchain, and the process start time and
+uptime.
+*/
+func GetBuildInfo(version string, includeDependencies bool) BuildInfo {
+	result := BuildInfo{
+		GoVersion: runtime.Version(),
+		StartTime: processStartTime,
+		Uptime:    time.Since(processStartTime).Round(time.Second).String(),
+		Version:   version,
+	}
+
+	info, ok := debug.ReadBuildInfo()
+
+	if !ok {
+		return result
+	}
+
+	result.Module = info.Main.Path
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs":
+			result.vcs().System = setting.Value
+		case "vcs.revision":
+			result.vcs().Revision = setting.Value
+		case "vcs.time":
+			result.vcs().Time = setting.Value
+		case "vcs.modified":
+			result.vcs().Modified = setting.Value == "true"
+		}
+	}
+
+	if !includeDependencies {
+		return result
+	}
+
+	result.Dependencies = make([]DependencyInfo, 0, len(info.Deps))
+
+	for _, dep := range info.Deps {
+		dependency := DependencyInfo{
+			Path:    dep.Path,
+			Version: dep.Version,
+		}
+
+		if dep.Replace != nil {
+			dependency.Replace = dep.Replace.Path
+
+			if dep.Replace.Version != "" {
+				dependency.Replace += "@" + dep.Replace.Version
+			}
+		}
+
+		result.Dependencies = append(result.Dependencies, dependency)
+	}
+
+	return result
+}
+
+func (b *BuildInfo) vcs() *VCSInfo {
+	if b.VCS == nil {
+		b.VCS = &VCSInfo{}
+	}
+
+	return b.VCS
+}
+
+/*
+VersionHandler returns a handler that writes the build information
+as JSON.
+
+Example:
+
+  router.HandleFunc("/version", nerdweb.VersionHandler(nerdweb.VersionOptions{
+    Version:          "1.4.0",
+    HideDependencies: true,
+  }))
+*/
+func VersionHandler(options VersionOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		WriteJSONWith(nil, w, http.StatusOK, GetBuildInfo(options.Version, !options.HideDependencies))
+	}
+}
@@ -0,0 +1,85 @@
+package nerdweb_test
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	tests := []struct {
+		name             string
+		newRouter        func() http.Handler
+		path             string
+		wantVersion      string
+		wantDependencies bool
+	}{
+		{
+			name: "Serves build information at /version",
+			newRouter: func() http.Handler {
+				router, _ := nerdweb.NewServer(nerdweb.WithVersion(nerdweb.VersionOptions{Version: "1.4.0"}))
+				return router
+			},
+			path:             "/version",
+			wantVersion:      "1.4.0",
+			wantDependencies: true,
+		},
+		{
+			name: "Hides dependencies at a custom path",
+			newRouter: func() http.Handler {
+				router, _ := nerdweb.NewServer(nerdweb.WithVersion(nerdweb.VersionOptions{Version: "1.4.0", Path: "/about", HideDependencies: true}))
+				return router
+			},
+			path:             "/about",
+			wantVersion:      "1.4.0",
+			wantDependencies: false,
+		},
+		{
+			name: "Uses the basic web app config version",
+			newRouter: func() http.Handler {
+				config := nerdweb.DefaultBasicWebAppConfig("localhost:8080", "2.0.0", embed.FS{})
+				config.VersionEndpoint = &nerdweb.VersionOptions{}
+
+				router, _ := nerdweb.NewBasicWebAppRouterAndServer(config)
+				return router
+			},
+			path:             "/version",
+			wantVersion:      "2.0.0",
+			wantDependencies: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("wanted status 200, got %d", w.Code)
+			}
+
+			got := map[string]interface{}{}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("expected JSON, got %s: %v", w.Body.String(), err)
+			}
+
+			if got["version"] != tt.wantVersion || got["goVersion"] != runtime.Version() {
+				t.Errorf("wanted version %s and go version %s, got %v and %v", tt.wantVersion, runtime.Version(), got["version"], got["goVersion"])
+			}
+
+			if got["startTime"] == nil || got["uptime"] == nil {
+				t.Errorf("expected start time and uptime, got %v", got)
+			}
+
+			if _, gotDependencies := got["dependencies"]; gotDependencies != tt.wantDependencies {
+				t.Errorf("wanted dependencies %v, got %v", tt.wantDependencies, got["dependencies"])
+			}
+		})
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/app-nerds/nerdweb/v2
 
-go 1.16
+go 1.21
 
 require (
 	github.com/gorilla/mux v1.8.0
@@ -1,7 +1,6 @@
 package middlewares
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 )
@@ -14,15 +13,15 @@ Example:
   mux := nerdweb.NewServeMux()
   mux.HandleFunc("/endpoint", middlewares.Allow(myHandler, http.MethodPost))
 
-If the caller's method does not match what is allowed, the string
-"method not allowed" is written back to the caller.
+If the caller's method does not match what is allowed, a problem
+response with the detail "method not allowed" is written back to
+the caller.
 */
 func Allow(next http.HandlerFunc, allowedMethod string) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.ToLower(r.Method) != strings.ToLower(allowedMethod) {
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			_, _ = fmt.Fprintf(w, "%s", "method not allowed")
+			w.Header().Set("Allow", strings.ToUpper(allowedMethod))
+			writeProblem(w, http.StatusMethodNotAllowed, "method not allowed")
 
 			return
 		}
@@ -0,0 +1,263 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+CORSConfig configures the CORS middleware.
+
+AllowedOrigins may contain exact origins ("https://example.com"),
+wildcard subdomains ("https://*.example.com"), or "*" to allow
+any origin. AllowOriginFunc, when set, is consulted for origins
+that do not match AllowedOrigins. "*" cannot be combined with
+AllowCredentials, since that would let any site make credentialed
+requests; list the origins or use AllowOriginFunc instead.
+*/
+type CORSConfig struct {
+	AllowCredentials bool
+	AllowOriginFunc  func(origin string) bool
+	AllowedHeaders   []string
+	AllowedMethods   []string
+	AllowedOrigins   []string
+	ExposedHeaders   []string
+	MaxAge           int
+}
+
+/*
+AllowAllCORSConfig returns a CORS configuration that allows any
+origin, the common HTTP methods, and the common request headers.
+This matches the behavior of AccessControl used with AllowAllOrigins,
+AllowAllMethods, and AllowAllHeaders.
+*/
+func AllowAllCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedHeaders: []string{
+			AllowHeaderAccept,
+			AllowHeaderContentType,
+			AllowHeaderContentLength,
+			AllowHeaderAcceptEncoding,
+			AllowHeaderCSRF,
+			AllowHeaderAuthorization,
+		},
+		AllowedMethods: []string{http.MethodPost, http.MethodGet, http.MethodOptions, http.MethodPut, http.MethodDelete},
+		AllowedOrigins: []string{AllowAllOrigins},
+	}
+}
+
+type cors struct {
+	handler        http.Handler
+	config         CORSConfig
+	allowAny       bool
+	allowedHeaders map[string]bool
+	allowedMethods map[string]bool
+}
+
+func newCORS(config CORSConfig) *cors {
+	c := &cors{
+		config:         config,
+		allowedHeaders: make(map[string]bool),
+		allowedMethods: make(map[string]bool),
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if origin == AllowAllOrigins {
+			c.allowAny = true
+		}
+	}
+
+	if c.allowAny && config.AllowCredentials {
+		panic(`middlewares: CORS AllowedOrigins "*" cannot be used with AllowCredentials`)
+	}
+
+	for _, header := range config.AllowedHeaders {
+		c.allowedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	for _, method := range config.AllowedMethods {
+		c.allowedMethods[strings.ToUpper(method)] = true
+	}
+
+	return c
+}
+
+func (c *cors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	if preflight {
+		c.handlePreflight(w, r, origin)
+		return
+	}
+
+	if !c.wildcardResponse() {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if origin != "" && c.isOriginAllowed(origin) {
+		c.setOriginHeaders(w, origin)
+
+		if len(c.config.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.config.ExposedHeaders, ", "))
+		}
+	}
+
+	c.handler.ServeHTTP(w, r)
+}
+
+func (c *cors) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	w.Header().Add("Vary", "Origin")
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	requestedMethod := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requestedHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	if origin == "" || !c.isOriginAllowed(origin) || !c.isMethodAllowed(requestedMethod) || !c.areHeadersAllowed(requestedHeaders) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.setOriginHeaders(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", requestedMethod)
+
+	if len(requestedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+
+	if c.config.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(c.config.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) setOriginHeaders(w http.ResponseWriter, origin string) {
+	if c.wildcardResponse() {
+		w.Header().Set("Access-Control-Allow-Origin", AllowAllOrigins)
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+/*
+wildcardResponse is true when every origin receives the same
+"*" response.
+*/
+func (c *cors) wildcardResponse() bool {
+	return c.allowAny
+}
+
+func (c *cors) isOriginAllowed(origin string) bool {
+	if c.allowAny {
+		return true
+	}
+
+	for _, allowed := range c.config.AllowedOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+
+	if c.config.AllowOriginFunc != nil {
+		return c.config.AllowOriginFunc(origin)
+	}
+
+	return false
+}
+
+func (c *cors) isMethodAllowed(method string) bool {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodPost {
+		return true
+	}
+
+	return c.allowedMethods[method]
+}
+
+func (c *cors) areHeadersAllowed(headers []string) bool {
+	if c.allowedHeaders["*"] {
+		return true
+	}
+
+	for _, header := range headers {
+		if !c.allowedHeaders[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchOrigin(pattern, origin string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, origin)
+	}
+
+	parts := strings.SplitN(strings.ToLower(pattern), "*", 2)
+	origin = strings.ToLower(origin)
+
+	return len(origin) > len(parts[0])+len(parts[1]) &&
+		strings.HasPrefix(origin, parts[0]) &&
+		strings.HasSuffix(origin, parts[1])
+}
+
+func parseHeaderList(value string) []string {
+	result := []string{}
+
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			result = append(result, header)
+		}
+	}
+
+	return result
+}
+
+/*
+CORS returns a middleware that applies Cross-Origin Resource Sharing
+rules. Preflight requests are answered with a 204 response and never
+reach the handler. It panics when AllowedOrigins contains "*" and
+AllowCredentials is set.
+
+Example:
+
+  config := middlewares.CORSConfig{
+    AllowedOrigins:   []string{"https://example.com", "https://*.example.com"},
+    AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+    AllowedHeaders:   []string{"Content-Type", "Authorization"},
+    AllowCredentials: true,
+    MaxAge:           600,
+  }
+
+  mux.Use(middlewares.CORS(config))
+
+Note that Gorilla Mux only runs middlewares for matched routes. Servers
+created by nerdweb also wrap the router's NotFoundHandler and
+MethodNotAllowedHandler with this middleware, so preflight requests for
+routes without an OPTIONS method reach it. Do the same on your own
+router:
+
+  cors := middlewares.CORS(config)
+  router.Use(cors)
+  router.NotFoundHandler = cors(http.NotFoundHandler())
+  router.MethodNotAllowedHandler = cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusMethodNotAllowed)
+  }))
+*/
+func CORS(config CORSConfig) mux.MiddlewareFunc {
+	policy := newCORS(config)
+
+	return func(next http.Handler) http.Handler {
+		handler := *policy
+		handler.handler = next
+		return &handler
+	}
+}
@@ -0,0 +1,185 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+)
+
+func TestCORS(t *testing.T) {
+	config := middlewares.CORSConfig{
+		AllowCredentials: true,
+		AllowOriginFunc:  func(origin string) bool { return origin == "https://partner.io" },
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedMethods:   []string{http.MethodPut},
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		ExposedHeaders:   []string{"X-Total-Count"},
+		MaxAge:           600,
+	}
+
+	tests := []struct {
+		name              string
+		method            string
+		origin            string
+		requestMethod     string
+		requestHeaders    string
+		wantStatus        int
+		wantAllowOrigin   string
+		wantAllowHeaders  string
+		wantExposeHeaders string
+		wantMaxAge        string
+		wantHandlerCalled bool
+	}{
+		{
+			name:              "Allows an exact origin and exposes headers",
+			method:            http.MethodGet,
+			origin:            "https://example.com",
+			wantStatus:        http.StatusOK,
+			wantAllowOrigin:   "https://example.com",
+			wantExposeHeaders: "X-Total-Count",
+			wantHandlerCalled: true,
+		},
+		{
+			name:              "Allows a wildcard subdomain",
+			method:            http.MethodGet,
+			origin:            "https://api.example.org",
+			wantStatus:        http.StatusOK,
+			wantAllowOrigin:   "https://api.example.org",
+			wantExposeHeaders: "X-Total-Count",
+			wantHandlerCalled: true,
+		},
+		{
+			name:              "Does not allow the bare domain for a wildcard subdomain",
+			method:            http.MethodGet,
+			origin:            "https://example.org",
+			wantStatus:        http.StatusOK,
+			wantHandlerCalled: true,
+		},
+		{
+			name:              "Allows origins accepted by the predicate",
+			method:            http.MethodGet,
+			origin:            "https://partner.io",
+			wantStatus:        http.StatusOK,
+			wantAllowOrigin:   "https://partner.io",
+			wantExposeHeaders: "X-Total-Count",
+			wantHandlerCalled: true,
+		},
+		{
+			name:              "Answers an allowed preflight without calling the handler",
+			method:            http.MethodOptions,
+			origin:            "https://example.com",
+			requestMethod:     http.MethodPut,
+			requestHeaders:    "content-type, authorization",
+			wantStatus:        http.StatusNoContent,
+			wantAllowOrigin:   "https://example.com",
+			wantAllowHeaders:  "content-type, authorization",
+			wantMaxAge:        "600",
+			wantHandlerCalled: false,
+		},
+		{
+			name:              "Answers a preflight for a disallowed method without CORS headers",
+			method:            http.MethodOptions,
+			origin:            "https://example.com",
+			requestMethod:     http.MethodDelete,
+			wantStatus:        http.StatusNoContent,
+			wantHandlerCalled: false,
+		},
+		{
+			name:              "Answers a preflight for disallowed headers without CORS headers",
+			method:            http.MethodOptions,
+			origin:            "https://example.com",
+			requestMethod:     http.MethodPut,
+			requestHeaders:    "X-Secret",
+			wantStatus:        http.StatusNoContent,
+			wantHandlerCalled: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlerCalled := false
+			handler := middlewares.CORS(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerCalled = true
+			}))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.Header.Set("Origin", tt.origin)
+
+			if tt.requestMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+
+			if tt.requestHeaders != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+			}
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if handlerCalled != tt.wantHandlerCalled {
+				t.Errorf("wanted handler called %v, got %v", tt.wantHandlerCalled, handlerCalled)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("wanted allow origin '%s', got '%s'", tt.wantAllowOrigin, got)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Headers"); got != tt.wantAllowHeaders {
+				t.Errorf("wanted allow headers '%s', got '%s'", tt.wantAllowHeaders, got)
+			}
+
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != tt.wantExposeHeaders {
+				t.Errorf("wanted expose headers '%s', got '%s'", tt.wantExposeHeaders, got)
+			}
+
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("wanted max age '%s', got '%s'", tt.wantMaxAge, got)
+			}
+
+			if tt.wantAllowOrigin != "" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("expected credentials to be allowed")
+			}
+
+			if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Origin") {
+				t.Errorf("expected Vary: Origin, got %v", w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSAllowAll(t *testing.T) {
+	handler := middlewares.CORS(middlewares.AllowAllCORSConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://anywhere.com")
+	handler.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("wanted allow origin '*', got '%s'", got)
+	}
+
+	if len(w.Header().Values("Vary")) != 0 {
+		t.Errorf("did not expect a Vary header, got %v", w.Header().Values("Vary"))
+	}
+}
+
+func TestCORSRejectsAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("wanted a panic for AllowedOrigins '*' with AllowCredentials")
+		}
+	}()
+
+	config := middlewares.AllowAllCORSConfig()
+	config.AllowCredentials = true
+
+	middlewares.CORS(config)
+}
@@ -1,7 +1,6 @@
 package middlewares
 
 import (
-	"context"
 	"net/http"
 	"strings"
 
@@ -10,8 +9,8 @@ import (
 
 /*
 CaptureAuth captures an authorization token from an Authorization
-header and stored it in a context variable named "authtoken". This
-middleware expect the header to be in the format of:
+header and stores it in the request context. Use AuthTokenFromContext
+to read it. This middleware expect the header to be in the format of:
 
   Authorization: Bearer <token here>
 
@@ -30,6 +29,15 @@ Here is an example:
   http.HandleFunc("/endpoint", middlewares.CaptureAuth(handlerFunc, logger, onInvalidHeader))
 */
 func CaptureAuth(next http.HandlerFunc, logger *logrus.Entry, onInvalidHeader func(logger *logrus.Entry, w http.ResponseWriter)) http.HandlerFunc {
+	return CaptureAuthWith(next, NewLogrusLogger(logger), func(_ Logger, w http.ResponseWriter) {
+		onInvalidHeader(logger, w)
+	})
+}
+
+/*
+CaptureAuthWith is CaptureAuth for any Logger.
+*/
+func CaptureAuthWith(next http.HandlerFunc, logger Logger, onInvalidHeader func(logger Logger, w http.ResponseWriter)) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		auth := strings.SplitN(authHeader, " ", 2)
@@ -41,7 +49,7 @@ func CaptureAuth(next http.HandlerFunc, logger *logrus.Entry, onInvalidHeader fu
 		}
 
 		token := auth[1]
-		ctx := context.WithValue(r.Context(), "authtoken", token)
+		ctx := withContextValue(r.Context(), authTokenContextKey, token)
 
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
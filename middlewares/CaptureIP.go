@@ -1,25 +1,25 @@
 package middlewares
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
 type captureIP struct {
-	handler http.Handler
+	handler  http.Handler
+	resolver *IPResolver
 }
 
 func (c *captureIP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := context.WithValue(r.Context(), "ip", realIP(r))
+	ctx := withContextValue(r.Context(), ipContextKey, resolveIP(c.resolver, r))
 	r = r.WithContext(ctx)
 	c.handler.ServeHTTP(w, r)
 }
 
 /*
 CaptureIP captures the caller's IP address and puts it into the
-context as "ip". Example:
+request context. Use IPFromContext to read it. Example:
 
   mux := nerdweb.NewServeMux()
   mux.HandleFunc("/endpoint", handler)
@@ -27,9 +27,24 @@ context as "ip". Example:
   mux.Use(middlewares.CaptureIP())
 */
 func CaptureIP() mux.MiddlewareFunc {
+	return CaptureIPWith(nil)
+}
+
+/*
+CaptureIPWith is CaptureIP using resolver to decide which proxies are
+trusted. When resolver is nil the one installed by TrustedProxies is
+used. Example:
+
+  resolver, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{
+    TrustedProxies: []string{"10.1.0.0/16"},
+  })
+
+  mux.Use(middlewares.CaptureIPWith(resolver))
+*/
+func CaptureIPWith(resolver *IPResolver) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			handler := &captureIP{handler: next}
+			handler := &captureIP{handler: next, resolver: resolver}
 			handler.ServeHTTP(w, r)
 		})
 	}
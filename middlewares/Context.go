@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const (
+	authTokenContextKey  contextKey = "authtoken"
+	claimsContextKey     contextKey = "claims"
+	ipContextKey         contextKey = "ip"
+	ipResolverContextKey contextKey = "ipResolver"
+	loggerContextKey     contextKey = "logger"
+	requestIDContextKey  contextKey = "requestID"
+	spanContextKey       contextKey = "spanContext"
+)
+
+/*
+LegacyContextKeys controls compatibility with the bare string context
+keys ("ip" and "authtoken") used by earlier versions. When true,
+middlewares also store values under the old keys, and the accessor
+functions fall back to reading them. Set this to false once your code
+uses the accessor functions.
+*/
+var LegacyContextKeys = true
+
+/*
+IPFromContext returns the caller's IP address stored by CaptureIP.
+The second return value is false if there is no IP address.
+*/
+func IPFromContext(r *http.Request) (string, bool) {
+	return stringFromContext(r.Context(), ipContextKey)
+}
+
+/*
+AuthTokenFromContext returns the authorization token stored by
+CaptureAuth or VerifyJWT. The second return value is false if there
+is no token.
+*/
+func AuthTokenFromContext(r *http.Request) (string, bool) {
+	return stringFromContext(r.Context(), authTokenContextKey)
+}
+
+/*
+ClaimsFromContext returns the verified JWT claims stored by VerifyJWT.
+The second return value is false if there are no claims.
+*/
+func ClaimsFromContext(r *http.Request) (*Claims, bool) {
+	claims, ok := r.Context().Value(claimsContextKey).(*Claims)
+	return claims, ok
+}
+
+func withContextValue(ctx context.Context, key contextKey, value interface{}) context.Context {
+	ctx = context.WithValue(ctx, key, value)
+
+	if LegacyContextKeys {
+		ctx = context.WithValue(ctx, string(key), value)
+	}
+
+	return ctx
+}
+
+func stringFromContext(ctx context.Context, key contextKey) (string, bool) {
+	if value, ok := ctx.Value(key).(string); ok {
+		return value, true
+	}
+
+	if LegacyContextKeys {
+		value, ok := ctx.Value(string(key)).(string)
+		return value, ok
+	}
+
+	return "", false
+}
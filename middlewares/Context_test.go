@@ -0,0 +1,61 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+func TestContextAccessors(t *testing.T) {
+	logger := logrus.New().WithField("who", "testing")
+
+	tests := []struct {
+		name       string
+		legacy     bool
+		wantLegacy bool
+	}{
+		{
+			name:       "Stores values under typed and legacy keys in compatibility mode",
+			legacy:     true,
+			wantLegacy: true,
+		},
+		{
+			name:       "Stores values only under typed keys when compatibility mode is off",
+			legacy:     false,
+			wantLegacy: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middlewares.LegacyContextKeys = tt.legacy
+			defer func() { middlewares.LegacyContextKeys = true }()
+
+			handler := middlewares.CaptureIP()(middlewares.CaptureAuth(func(w http.ResponseWriter, r *http.Request) {
+				if ip, ok := middlewares.IPFromContext(r); !ok || ip != "127.0.0.1" {
+					t.Errorf("wanted IP 127.0.0.1, got '%s'", ip)
+				}
+
+				if token, ok := middlewares.AuthTokenFromContext(r); !ok || token != "abc" {
+					t.Errorf("wanted token abc, got '%s'", token)
+				}
+
+				_, gotLegacy := r.Context().Value("authtoken").(string)
+
+				if gotLegacy != tt.wantLegacy {
+					t.Errorf("wanted legacy key present %v, got %v", tt.wantLegacy, gotLegacy)
+				}
+			}, logger, func(logger *logrus.Entry, w http.ResponseWriter) {
+				t.Errorf("did not expect an invalid header")
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "127.0.0.1"
+			r.Header.Set("Authorization", "Bearer abc")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	IPHeaderForwarded     = "Forwarded"
+	IPHeaderXForwardedFor = "X-Forwarded-For"
+	IPHeaderXRealIP       = "X-Real-IP"
+)
+
+/*
+IPResolver determines the IP address of the caller. The forwarding
+header is only honored when the request comes from a trusted proxy,
+and only the one header the proxy sets is read; the others may come
+straight from the client. Address chains are walked right-to-left,
+skipping trusted proxies, so the first untrusted address is the client.
+*/
+type IPResolver struct {
+	header         string
+	trustedProxies []netip.Prefix
+}
+
+/*
+IPResolverOptions configures NewIPResolver.
+
+Header is the forwarding header your proxy sets: IPHeaderForwarded
+(RFC 7239), IPHeaderXForwardedFor, or IPHeaderXRealIP. Defaults to
+IPHeaderXForwardedFor. TrustedProxies lists the proxies whose header
+is believed. Each may be a CIDR ("10.0.0.0/8") or a single IP
+address. With no trusted proxies the header is ignored and the
+remote address is always used.
+*/
+type IPResolverOptions struct {
+	Header         string
+	TrustedProxies []string
+}
+
+/*
+defaultIPResolver is used when no resolver is configured. It only
+trusts proxies on loopback, since any other host could be a client
+sending forged headers.
+*/
+var defaultIPResolver = &IPResolver{
+	header: IPHeaderXForwardedFor,
+	trustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	},
+}
+
+/*
+NewIPResolver creates a resolver that reads the caller's IP address
+from the header set by the trusted proxies.
+
+Example:
+
+  resolver, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{
+    Header:         middlewares.IPHeaderXForwardedFor,
+    TrustedProxies: []string{"10.1.0.0/16", "192.0.2.10"},
+  })
+*/
+func NewIPResolver(options IPResolverOptions) (*IPResolver, error) {
+	result := &IPResolver{
+		header:         http.CanonicalHeaderKey(options.Header),
+		trustedProxies: make([]netip.Prefix, 0, len(options.TrustedProxies)),
+	}
+
+	switch result.header {
+	case "":
+		result.header = IPHeaderXForwardedFor
+	case IPHeaderForwarded, IPHeaderXForwardedFor, http.CanonicalHeaderKey(IPHeaderXRealIP):
+	default:
+		return nil, fmt.Errorf("unsupported forwarding header '%s'", options.Header)
+	}
+
+	for _, proxy := range options.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s': %w", proxy, err)
+			}
+
+			result.trustedProxies = append(result.trustedProxies, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(proxy)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %w", proxy, err)
+		}
+
+		addr = addr.Unmap()
+		result.trustedProxies = append(result.trustedProxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return result, nil
+}
+
+/*
+TrustedProxies returns a middleware that makes resolver the one used
+to find the caller's IP address for the rest of the request, by
+RealIP, CaptureIP, RequestLogger, Recover, Tracing, and the
+request-scoped logger. Without it only loopback proxies are trusted.
+Install it before the middlewares that use the IP address.
+
+Example:
+
+  resolver, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{
+    TrustedProxies: []string{"10.1.0.0/16", "192.0.2.10"},
+  })
+
+  mux.Use(middlewares.TrustedProxies(resolver))
+  mux.Use(middlewares.CaptureIP())
+*/
+func TrustedProxies(resolver *IPResolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ipResolverContextKey, resolver)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+/*
+IPResolverFromContext returns the resolver installed by TrustedProxies,
+or one that only trusts loopback proxies when there is none.
+*/
+func IPResolverFromContext(r *http.Request) *IPResolver {
+	if resolver, ok := r.Context().Value(ipResolverContextKey).(*IPResolver); ok && resolver != nil {
+		return resolver
+	}
+
+	return defaultIPResolver
+}
+
+/*
+Resolve returns the caller's IP address. The result is invalid if
+the remote address cannot be parsed.
+*/
+func (ipr *IPResolver) Resolve(r *http.Request) netip.Addr {
+	remote := parseHostAddr(r.RemoteAddr)
+
+	if !remote.IsValid() || !ipr.isTrusted(remote) {
+		return remote
+	}
+
+	values := r.Header.Values(ipr.header)
+
+	if len(values) == 0 {
+		return remote
+	}
+
+	switch ipr.header {
+	case IPHeaderForwarded:
+		return ipr.walk(remote, parseForwarded(values))
+	case IPHeaderXForwardedFor:
+		return ipr.walk(remote, splitList(values))
+	}
+
+	if xRealIP := parseHostAddr(strings.TrimSpace(values[0])); xRealIP.IsValid() {
+		return xRealIP
+	}
+
+	return remote
+}
+
+func (ipr *IPResolver) walk(remote netip.Addr, chain []string) netip.Addr {
+	result := remote
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr := parseHostAddr(chain[i])
+
+		if !addr.IsValid() {
+			break
+		}
+
+		result = addr
+
+		if !ipr.isTrusted(addr) {
+			break
+		}
+	}
+
+	return result
+}
+
+func (ipr *IPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range ipr.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+parseHostAddr parses an IP address that may include a port and
+IPv6 brackets, such as "192.0.2.1:8080" or "[2001:db8::1]:443".
+*/
+func parseHostAddr(value string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap()
+	}
+
+	return netip.Addr{}
+}
+
+func splitList(values []string) []string {
+	result := []string{}
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			result = append(result, strings.TrimSpace(item))
+		}
+	}
+
+	return result
+}
+
+/*
+parseForwarded returns the "for" parameter of each element in
+RFC 7239 Forwarded headers.
+*/
+func parseForwarded(values []string) []string {
+	result := []string{}
+
+	for _, element := range splitList(values) {
+		forValue := ""
+
+		for _, pair := range strings.Split(element, ";") {
+			name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+
+			if found && strings.EqualFold(name, "for") {
+				forValue = strings.Trim(value, `"`)
+			}
+		}
+
+		result = append(result, forValue)
+	}
+
+	return result
+}
+
+func realIP(r *http.Request) string {
+	return resolveIP(nil, r)
+}
+
+/*
+resolveIP returns the caller's IP address as a string, falling back
+to the remote address. When resolver is nil the one from the request
+context is used.
+*/
+func resolveIP(resolver *IPResolver, r *http.Request) string {
+	if resolver == nil {
+		resolver = IPResolverFromContext(r)
+	}
+
+	if addr := resolver.Resolve(r); addr.IsValid() {
+		return addr.String()
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,175 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/gorilla/mux"
+)
+
+func TestIPResolver(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		remoteAddr string
+		headers    http.Header
+		want       string
+	}{
+		{
+			name:       "Returns the remote address without a port",
+			remoteAddr: "203.0.113.5:1234",
+			headers:    http.Header{},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "Returns an IPv6 remote address without brackets or port",
+			remoteAddr: "[2001:db8::1]:443",
+			headers:    http.Header{},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "Ignores headers from untrusted remote addresses",
+			remoteAddr: "203.0.113.5:1234",
+			headers:    http.Header{"X-Forwarded-For": []string{"198.51.100.1"}, "X-Real-Ip": []string{"198.51.100.2"}},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "Walks X-Forwarded-For right-to-left past trusted proxies",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": []string{"6.6.6.6, 198.51.100.1", "10.1.1.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Returns the leftmost address when every hop is trusted",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": []string{"10.2.2.2, 192.0.2.10"}},
+			want:       "10.2.2.2",
+		},
+		{
+			name:       "Understands the Forwarded header",
+			header:     middlewares.IPHeaderForwarded,
+			remoteAddr: "192.0.2.10:1234",
+			headers:    http.Header{"Forwarded": []string{`for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.3`}},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "Ignores a spoofed Forwarded header next to X-Forwarded-For",
+			remoteAddr: "192.0.2.10:1234",
+			headers:    http.Header{"Forwarded": []string{"for=1.2.3.4"}, "X-Forwarded-For": []string{"198.51.100.61"}},
+			want:       "198.51.100.61",
+		},
+		{
+			name:       "Ignores a spoofed X-Real-IP header next to X-Forwarded-For",
+			remoteAddr: "192.0.2.10:1234",
+			headers:    http.Header{"X-Real-Ip": []string{"1.2.3.4"}, "X-Forwarded-For": []string{"198.51.100.61"}},
+			want:       "198.51.100.61",
+		},
+		{
+			name:       "Ignores a spoofed X-Forwarded-For header next to Forwarded",
+			header:     middlewares.IPHeaderForwarded,
+			remoteAddr: "192.0.2.10:1234",
+			headers:    http.Header{"Forwarded": []string{"for=198.51.100.60"}, "X-Forwarded-For": []string{"1.2.3.4"}},
+			want:       "198.51.100.60",
+		},
+		{
+			name:       "Stops at obfuscated Forwarded identifiers",
+			header:     middlewares.IPHeaderForwarded,
+			remoteAddr: "192.0.2.10:1234",
+			headers:    http.Header{"Forwarded": []string{"for=_hidden, for=10.0.0.9"}},
+			want:       "10.0.0.9",
+		},
+		{
+			name:       "Uses X-Real-IP from a trusted proxy",
+			header:     middlewares.IPHeaderXRealIP,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Real-Ip": []string{"198.51.100.9"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "Does not use X-Real-IP by default",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Real-Ip": []string{"198.51.100.9"}},
+			want:       "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{
+				Header:         tt.header,
+				TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"},
+			})
+
+			if err != nil {
+				t.Fatalf("did not expect an error: %s", err)
+			}
+
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: tt.headers}
+			got := resolver.Resolve(r)
+
+			if got.String() != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewIPResolverInvalidOptions(t *testing.T) {
+	if _, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{TrustedProxies: []string{"not-an-ip"}}); err == nil {
+		t.Errorf("wanted an error for an invalid proxy")
+	}
+
+	if _, err := middlewares.NewIPResolver(middlewares.IPResolverOptions{Header: "X-Client-IP"}); err == nil {
+		t.Errorf("wanted an error for an unsupported header")
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	resolver, _ := middlewares.NewIPResolver(middlewares.IPResolverOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+
+	tests := []struct {
+		name       string
+		middleware []mux.MiddlewareFunc
+		want       string
+	}{
+		{
+			name:       "Only trusts loopback by default",
+			middleware: []mux.MiddlewareFunc{middlewares.CaptureIP()},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "Uses the resolver installed by TrustedProxies",
+			middleware: []mux.MiddlewareFunc{middlewares.TrustedProxies(resolver), middlewares.CaptureIP()},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "Uses the resolver given to CaptureIPWith",
+			middleware: []mux.MiddlewareFunc{middlewares.CaptureIPWith(resolver)},
+			want:       "198.51.100.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+
+			router := mux.NewRouter()
+			router.Use(tt.middleware...)
+			router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				got, _ = middlewares.IPFromContext(r)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.2:40000"
+			r.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
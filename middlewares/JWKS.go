@@ -0,0 +1,391 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJWKSUnknownKey = errors.New("no key found for token")
+)
+
+/*
+JWKSConfig configures a JSON Web Key Set. Set either URL or File.
+
+RefreshInterval is how often the key set is reloaded. Defaults to
+one hour. When a token references an unknown key ID the key set is
+reloaded immediately, but no more often than MinRefreshInterval,
+which defaults to one minute. Keys with a type or curve that is not
+supported, such as OKP, are skipped and logged to Logger.
+*/
+type JWKSConfig struct {
+	File               string
+	HTTPClient         *http.Client
+	Logger             Logger
+	MinRefreshInterval time.Duration
+	Now                func() time.Time
+	RefreshInterval    time.Duration
+	URL                string
+}
+
+/*
+JWKS is a JSON Web Key Set used to select token verification keys
+by key ID. It is safe for concurrent use.
+*/
+type JWKS struct {
+	config      JWKSConfig
+	keys        map[string]jsonWebKey
+	lastAttempt time.Time
+	loadedAt    time.Time
+	mutex       sync.RWMutex
+	refreshLock sync.Mutex
+}
+
+type jsonWebKey struct {
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	E         string `json:"e"`
+	K         string `json:"k"`
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	N         string `json:"n"`
+	Use       string `json:"use"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+
+	key interface{}
+}
+
+/*
+NewJWKS creates a key set and loads it for the first time.
+
+Example:
+
+  jwks, err := middlewares.NewJWKS(middlewares.JWKSConfig{
+    URL: "https://auth.example.com/.well-known/jwks.json",
+  })
+
+  config := middlewares.JWTConfig{
+    Algorithms: []string{"RS256"},
+    KeyFunc:    jwks.KeyFunc,
+  }
+*/
+func NewJWKS(config JWKSConfig) (*JWKS, error) {
+	if config.URL == "" && config.File == "" {
+		return nil, fmt.Errorf("a JWKS URL or file is required")
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.Logger == nil {
+		config.Logger = defaultLogger()
+	}
+
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = time.Minute
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Hour
+	}
+
+	result := &JWKS{
+		config: config,
+		keys:   make(map[string]jsonWebKey),
+	}
+
+	if err := result.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+KeyFunc returns the key matching the token header's key ID. It is
+meant to be used as JWTConfig.KeyFunc.
+
+When the key set is due for a reload it is reloaded in the background
+while the cached keys keep being used. An unknown key ID waits for a
+reload, which is shared by every request that is waiting for one.
+*/
+func (j *JWKS) KeyFunc(header JWTHeader) (interface{}, error) {
+	j.mutex.RLock()
+	now := j.config.Now()
+	key, ok := j.find(header)
+	stale := now.Sub(j.loadedAt) >= j.config.RefreshInterval && j.refreshDue(now)
+	j.mutex.RUnlock()
+
+	if ok && stale {
+		go j.refreshInBackground()
+	}
+
+	if !ok {
+		if err := j.refreshIfDue(); err != nil {
+			return nil, err
+		}
+
+		j.mutex.RLock()
+		key, ok = j.find(header)
+		j.mutex.RUnlock()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: kid '%s'", ErrJWKSUnknownKey, header.KeyID)
+	}
+
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return nil, fmt.Errorf("%w: key %s is for algorithm %s", ErrTokenInvalidKey, key.KeyID, key.Algorithm)
+	}
+
+	return key.key, nil
+}
+
+/*
+Refresh reloads the key set from its URL or file.
+*/
+func (j *JWKS) Refresh() error {
+	j.refreshLock.Lock()
+	defer j.refreshLock.Unlock()
+
+	return j.refresh()
+}
+
+func (j *JWKS) find(header JWTHeader) (jsonWebKey, bool) {
+	if header.KeyID == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+
+	key, ok := j.keys[header.KeyID]
+	return key, ok
+}
+
+func (j *JWKS) refreshDue(now time.Time) bool {
+	return now.Sub(j.lastAttempt) >= j.config.MinRefreshInterval
+}
+
+/*
+refreshIfDue reloads the key set unless it was tried within
+MinRefreshInterval. Callers that arrive during a reload wait for it
+and then use its result instead of reloading again.
+*/
+func (j *JWKS) refreshIfDue() error {
+	j.refreshLock.Lock()
+	defer j.refreshLock.Unlock()
+
+	j.mutex.RLock()
+	due := j.refreshDue(j.config.Now())
+	j.mutex.RUnlock()
+
+	if !due {
+		return nil
+	}
+
+	return j.refresh()
+}
+
+func (j *JWKS) refreshInBackground() {
+	if !j.refreshLock.TryLock() {
+		return
+	}
+
+	defer j.refreshLock.Unlock()
+
+	j.mutex.RLock()
+	due := j.refreshDue(j.config.Now())
+	j.mutex.RUnlock()
+
+	if !due {
+		return
+	}
+
+	if err := j.refresh(); err != nil {
+		j.config.Logger.WithError(err).Error("error refreshing JWKS")
+	}
+}
+
+/*
+refresh loads the key set. It must be called with refreshLock held.
+The mutex is only taken to record the attempt and store the keys, so
+tokens keep being verified with the cached keys while the key set is
+fetched.
+*/
+func (j *JWKS) refresh() error {
+	var (
+		err  error
+		b    []byte
+		keys map[string]jsonWebKey
+	)
+
+	now := j.config.Now()
+
+	j.mutex.Lock()
+	j.lastAttempt = now
+	j.mutex.Unlock()
+
+	if b, err = j.load(); err != nil {
+		return err
+	}
+
+	if keys, err = parseJWKS(b, j.config.Logger); err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	j.keys = keys
+	j.loadedAt = now
+	j.mutex.Unlock()
+
+	return nil
+}
+
+func (j *JWKS) load() ([]byte, error) {
+	if j.config.File != "" {
+		b, err := os.ReadFile(j.config.File)
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading JWKS file: %w", err)
+		}
+
+		return b, nil
+	}
+
+	response, err := j.config.HTTPClient.Get(j.config.URL)
+
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JWKS: %w", err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching JWKS: unexpected status %d", response.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWKS response: %w", err)
+	}
+
+	return b, nil
+}
+
+func parseJWKS(b []byte, logger Logger) (map[string]jsonWebKey, error) {
+	var (
+		err error
+		set struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+	)
+
+	if err = json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("error parsing JWKS: %w", err)
+	}
+
+	result := make(map[string]jsonWebKey, len(set.Keys))
+
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		if key.key, err = key.publicKey(); err != nil {
+			logger.WithError(err).WithFields(LogFields{"kid": key.KeyID, "kty": key.KeyType}).Warn("skipping unusable JWKS key")
+			continue
+		}
+
+		result[key.KeyID] = key
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("error parsing JWKS: no usable signing keys")
+	}
+
+	return result, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Curve)
+		}
+
+		x, err := decodeBigInt(k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Curve)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+
+	return nil, fmt.Errorf("unsupported key type '%s'", k.KeyType)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
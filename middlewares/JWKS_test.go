@@ -0,0 +1,221 @@
+package middlewares_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+)
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+	}
+}
+
+func TestJWKSFromURL(t *testing.T) {
+	var (
+		requests int32
+		keySet   atomic.Value
+	)
+
+	now := time.Unix(1700000000, 0)
+	key1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key2, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	keySet.Store([]map[string]string{rsaJWK("key-1", key1)})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keySet.Load()})
+	}))
+	defer server.Close()
+
+	jwks, err := middlewares.NewJWKS(middlewares.JWKSConfig{
+		URL:                server.URL,
+		MinRefreshInterval: time.Minute,
+		Now:                func() time.Time { return now },
+	})
+
+	if err != nil {
+		t.Fatalf("did not expect an error loading JWKS: %s", err)
+	}
+
+	config := middlewares.JWTConfig{Algorithms: []string{"RS256"}, KeyFunc: jwks.KeyFunc}
+	claims := map[string]interface{}{"sub": "user-1"}
+
+	if _, err = middlewares.ValidateJWT(signTestJWT(t, "RS256", "key-1", key1, claims), config); err != nil {
+		t.Errorf("expected token signed by key-1 to be valid, got %s", err)
+	}
+
+	/*
+	 * Rotate keys. The unknown kid triggers a refetch.
+	 */
+	keySet.Store([]map[string]string{rsaJWK("key-2", key2)})
+	now = now.Add(2 * time.Minute)
+
+	if _, err = middlewares.ValidateJWT(signTestJWT(t, "RS256", "key-2", key2, claims), config); err != nil {
+		t.Errorf("expected token signed by rotated key-2 to be valid, got %s", err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("wanted 2 JWKS requests, got %d", got)
+	}
+
+	/*
+	 * Unknown kids within the minimum refresh interval do not refetch.
+	 */
+	_, err = middlewares.ValidateJWT(signTestJWT(t, "RS256", "key-3", key2, claims), config)
+
+	if !errors.Is(err, middlewares.ErrJWKSUnknownKey) {
+		t.Errorf("wanted unknown key error, got %v", err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("wanted refetches to be rate limited, got %d requests", got)
+	}
+}
+
+func TestJWKSFromFile(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	b, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{rsaJWK("file-key", key)}})
+	fileName := filepath.Join(t.TempDir(), "jwks.json")
+
+	if err := os.WriteFile(fileName, b, 0600); err != nil {
+		t.Fatalf("error writing JWKS file: %s", err)
+	}
+
+	jwks, err := middlewares.NewJWKS(middlewares.JWKSConfig{File: fileName})
+
+	if err != nil {
+		t.Fatalf("did not expect an error loading JWKS: %s", err)
+	}
+
+	config := middlewares.JWTConfig{KeyFunc: jwks.KeyFunc}
+	token := signTestJWT(t, "RS256", "file-key", key, map[string]interface{}{"sub": "user-1"})
+
+	if _, err = middlewares.ValidateJWT(token, config); err != nil {
+		t.Errorf("expected token to be valid, got %s", err)
+	}
+}
+
+func TestJWKSSkipsUnsupportedKeys(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	okp := map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed-key", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	ec := map[string]string{"kty": "EC", "crv": "secp256k1", "kid": "k1-key", "x": "AQ", "y": "AQ"}
+
+	tests := []struct {
+		name    string
+		keys    []map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Loads the supported keys",
+			keys: []map[string]string{okp, ec, rsaJWK("rsa-key", key)},
+		},
+		{
+			name:    "Fails when no key is usable",
+			keys:    []map[string]string{okp, ec},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := json.Marshal(map[string]interface{}{"keys": tt.keys})
+			fileName := filepath.Join(t.TempDir(), "jwks.json")
+
+			if err := os.WriteFile(fileName, b, 0600); err != nil {
+				t.Fatalf("error writing JWKS file: %s", err)
+			}
+
+			jwks, err := middlewares.NewJWKS(middlewares.JWKSConfig{File: fileName})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error loading JWKS")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("did not expect an error loading JWKS: %s", err)
+			}
+
+			config := middlewares.JWTConfig{Algorithms: []string{"RS256"}, KeyFunc: jwks.KeyFunc}
+			token := signTestJWT(t, "RS256", "rsa-key", key, map[string]interface{}{"sub": "user-1"})
+
+			if _, err = middlewares.ValidateJWT(token, config); err != nil {
+				t.Errorf("expected token to be valid, got %s", err)
+			}
+		})
+	}
+}
+
+func TestJWKSServesCachedKeysWhileRefreshing(t *testing.T) {
+	var (
+		now      atomic.Value
+		requests int32
+	)
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	release := make(chan struct{})
+	now.Store(time.Unix(1700000000, 0))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("key-1", key)}})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	jwks, err := middlewares.NewJWKS(middlewares.JWKSConfig{
+		URL:             server.URL,
+		Now:             func() time.Time { return now.Load().(time.Time) },
+		RefreshInterval: time.Hour,
+	})
+
+	if err != nil {
+		t.Fatalf("did not expect an error loading JWKS: %s", err)
+	}
+
+	now.Store(now.Load().(time.Time).Add(2 * time.Hour))
+
+	config := middlewares.JWTConfig{Algorithms: []string{"RS256"}, KeyFunc: jwks.KeyFunc}
+	token := signTestJWT(t, "RS256", "key-1", key, map[string]interface{}{"sub": "user-1"})
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := middlewares.ValidateJWT(token, config)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("expected token to be valid, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("wanted the cached key to be used while the key set is refreshed")
+	}
+}
@@ -0,0 +1,421 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenMalformed            = errors.New("token is malformed")
+	ErrTokenUnsupportedAlgorithm = errors.New("token algorithm is not allowed")
+	ErrTokenInvalidKey           = errors.New("key is not valid for the token algorithm")
+	ErrTokenSignatureInvalid     = errors.New("token signature is invalid")
+	ErrTokenExpired              = errors.New("token is expired")
+	ErrTokenNotValidYet          = errors.New("token is not valid yet")
+	ErrTokenUsedBeforeIssued     = errors.New("token used before issued")
+	ErrTokenInvalidIssuer        = errors.New("token has an invalid issuer")
+	ErrTokenInvalidAudience      = errors.New("token has an invalid audience")
+)
+
+/*
+JWTHeader is the decoded header of a JSON Web Token.
+*/
+type JWTHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+/*
+JWTKeyFunc returns the key used to verify a token. HMAC algorithms
+expect a []byte, RSA algorithms an *rsa.PublicKey, and ECDSA algorithms
+an *ecdsa.PublicKey.
+*/
+type JWTKeyFunc func(header JWTHeader) (interface{}, error)
+
+/*
+JWTConfig configures JWT verification.
+
+Algorithms restricts which "alg" values are accepted. When empty, any
+supported algorithm is accepted as long as the key type matches it.
+Supported algorithms are HS256, HS384, HS512, RS256, RS384, RS512,
+ES256, ES384, and ES512.
+
+Key is used to verify every token. KeyFunc, when set, is used instead
+to select a key from the token header.
+
+Issuer and Audience, when set, must match the "iss" and "aud" claims.
+ClockSkew is the leeway allowed when checking "exp", "nbf", and "iat".
+*/
+type JWTConfig struct {
+	Algorithms []string
+	Audience   string
+	ClockSkew  time.Duration
+	Issuer     string
+	Key        interface{}
+	KeyFunc    JWTKeyFunc
+	Now        func() time.Time
+}
+
+/*
+Claims are the verified claims of a JSON Web Token. The registered
+claims are parsed into fields, and every claim is available in Raw.
+Use Decode to read custom claims into a struct.
+*/
+type Claims struct {
+	Audience  []string
+	ExpiresAt time.Time
+	ID        string
+	IssuedAt  time.Time
+	Issuer    string
+	NotBefore time.Time
+	Raw       map[string]interface{}
+	Subject   string
+
+	payload []byte
+}
+
+/*
+Decode unmarshals the token's claims into dest.
+*/
+func (c *Claims) Decode(dest interface{}) error {
+	return json.Unmarshal(c.payload, dest)
+}
+
+type signingMethod struct {
+	family string
+	hash   crypto.Hash
+	curve  elliptic.Curve
+}
+
+var signingMethods = map[string]signingMethod{
+	"HS256": {family: "HS", hash: crypto.SHA256},
+	"HS384": {family: "HS", hash: crypto.SHA384},
+	"HS512": {family: "HS", hash: crypto.SHA512},
+	"RS256": {family: "RS", hash: crypto.SHA256},
+	"RS384": {family: "RS", hash: crypto.SHA384},
+	"RS512": {family: "RS", hash: crypto.SHA512},
+	"ES256": {family: "ES", hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {family: "ES", hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {family: "ES", hash: crypto.SHA512, curve: elliptic.P521()},
+}
+
+/*
+ValidateJWT verifies the signature of a compact serialized JWT and
+validates its time, issuer, and audience claims. The returned error
+wraps one of the ErrToken errors.
+*/
+func ValidateJWT(token string, config JWTConfig) (*Claims, error) {
+	var (
+		err       error
+		header    JWTHeader
+		headerB   []byte
+		payload   []byte
+		signature []byte
+		key       interface{}
+	)
+
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	if headerB, err = base64.RawURLEncoding.DecodeString(parts[0]); err != nil {
+		return nil, fmt.Errorf("%w: invalid header encoding", ErrTokenMalformed)
+	}
+
+	if err = json.Unmarshal(headerB, &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header", ErrTokenMalformed)
+	}
+
+	if payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload encoding", ErrTokenMalformed)
+	}
+
+	if signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", ErrTokenMalformed)
+	}
+
+	method, ok := signingMethods[header.Algorithm]
+
+	if !ok || !isAlgorithmAllowed(header.Algorithm, config.Algorithms) {
+		return nil, fmt.Errorf("%w: %s", ErrTokenUnsupportedAlgorithm, header.Algorithm)
+	}
+
+	key = config.Key
+
+	if config.KeyFunc != nil {
+		if key, err = config.KeyFunc(header); err != nil {
+			return nil, fmt.Errorf("error getting verification key: %w", err)
+		}
+	}
+
+	if err = verifySignature(method, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	claims, err := parseClaims(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateClaims(claims, config); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func isAlgorithmAllowed(alg string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, a := range allowed {
+		if a == alg {
+			return true
+		}
+	}
+
+	return false
+}
+
+func verifySignature(method signingMethod, key interface{}, signed, signature []byte) error {
+	hasher := method.hash.New()
+	_, _ = hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch method.family {
+	case "HS":
+		secret, ok := key.([]byte)
+
+		if !ok || len(secret) == 0 {
+			return ErrTokenInvalidKey
+		}
+
+		mac := hmac.New(method.hash.New, secret)
+		_, _ = mac.Write(signed)
+
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrTokenSignatureInvalid
+		}
+
+	case "RS":
+		publicKey, ok := key.(*rsa.PublicKey)
+
+		if !ok {
+			return ErrTokenInvalidKey
+		}
+
+		if rsa.VerifyPKCS1v15(publicKey, method.hash, digest, signature) != nil {
+			return ErrTokenSignatureInvalid
+		}
+
+	case "ES":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+
+		if !ok || publicKey.Curve != method.curve {
+			return ErrTokenInvalidKey
+		}
+
+		keySize := (publicKey.Curve.Params().BitSize + 7) / 8
+
+		if len(signature) != 2*keySize {
+			return ErrTokenSignatureInvalid
+		}
+
+		r := new(big.Int).SetBytes(signature[:keySize])
+		s := new(big.Int).SetBytes(signature[keySize:])
+
+		if !ecdsa.Verify(publicKey, digest, r, s) {
+			return ErrTokenSignatureInvalid
+		}
+	}
+
+	return nil
+}
+
+func parseClaims(payload []byte) (*Claims, error) {
+	var (
+		err error
+		raw map[string]interface{}
+	)
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: invalid claims", ErrTokenMalformed)
+	}
+
+	claims := &Claims{
+		Raw:     raw,
+		payload: payload,
+	}
+
+	claims.ID, _ = raw["jti"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+
+	switch aud := raw["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+
+	if claims.ExpiresAt, err = numericDate(raw, "exp"); err != nil {
+		return nil, err
+	}
+
+	if claims.NotBefore, err = numericDate(raw, "nbf"); err != nil {
+		return nil, err
+	}
+
+	if claims.IssuedAt, err = numericDate(raw, "iat"); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// maxNumericDate is 9999-12-31T23:59:59Z, the latest date claims may hold
+const maxNumericDate = 253402300799
+
+func numericDate(raw map[string]interface{}, name string) (time.Time, error) {
+	value, ok := raw[name]
+
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	number, ok := value.(json.Number)
+
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: claim %s is not a number", ErrTokenMalformed, name)
+	}
+
+	seconds, err := number.Float64()
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: claim %s is not a number", ErrTokenMalformed, name)
+	}
+
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds > maxNumericDate || seconds < -maxNumericDate {
+		return time.Time{}, fmt.Errorf("%w: claim %s is out of range", ErrTokenMalformed, name)
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), nil
+}
+
+func validateClaims(claims *Claims, config JWTConfig) error {
+	now := time.Now()
+
+	if config.Now != nil {
+		now = config.Now()
+	}
+
+	if !claims.ExpiresAt.IsZero() && now.After(claims.ExpiresAt.Add(config.ClockSkew)) {
+		return ErrTokenExpired
+	}
+
+	if !claims.NotBefore.IsZero() && now.Add(config.ClockSkew).Before(claims.NotBefore) {
+		return ErrTokenNotValidYet
+	}
+
+	if !claims.IssuedAt.IsZero() && now.Add(config.ClockSkew).Before(claims.IssuedAt) {
+		return ErrTokenUsedBeforeIssued
+	}
+
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return ErrTokenInvalidIssuer
+	}
+
+	if config.Audience != "" {
+		for _, aud := range claims.Audience {
+			if aud == config.Audience {
+				return nil
+			}
+		}
+
+		return ErrTokenInvalidAudience
+	}
+
+	return nil
+}
+
+/*
+VerifyJWT captures a bearer token from the Authorization header,
+verifies its signature and claims, and stores the claims in the
+request context. Use ClaimsFromContext to read them. The raw token
+is also stored in the context, just like CaptureAuth, and can be read
+with AuthTokenFromContext.
+
+If the header is missing or the token fails verification, the
+provided error method is called. Here is an example:
+
+  config := middlewares.JWTConfig{
+    Algorithms: []string{"RS256"},
+    Key:        publicKey,
+    Issuer:     "https://auth.example.com",
+    Audience:   "my-api",
+    ClockSkew:  30 * time.Second,
+  }
+
+  onInvalidToken := func(logger middlewares.Logger, w http.ResponseWriter) {
+    nerdweb.WriteProblem(logger, w, nerdweb.NewProblem(http.StatusUnauthorized, "invalid token"))
+  }
+
+  http.HandleFunc("/endpoint", middlewares.VerifyJWT(handlerFunc, logger, config, onInvalidToken))
+*/
+func VerifyJWT(next http.HandlerFunc, logger Logger, config JWTConfig, onInvalidToken func(logger Logger, w http.ResponseWriter)) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		auth := strings.SplitN(authHeader, " ", 2)
+
+		if len(auth) != 2 || auth[0] != "Bearer" {
+			logger.Error("invalid JWT authorization header. Expected 'Bearer <token here>'")
+			onInvalidToken(logger, w)
+			return
+		}
+
+		token := auth[1]
+		claims, err := ValidateJWT(token, config)
+
+		if err != nil {
+			logger.WithError(err).Error("invalid JWT")
+			onInvalidToken(logger, w)
+			return
+		}
+
+		ctx := withContextValue(r.Context(), authTokenContextKey, token)
+		ctx = context.WithValue(ctx, claimsContextKey, claims)
+		addLoggerFields(r, LogFields{"subject": claims.Subject})
+
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,287 @@
+package middlewares_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+func signTestJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+
+	case *rsa.PrivateKey:
+		var err error
+
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("error signing token: %s", err)
+		}
+
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+
+		if err != nil {
+			t.Fatalf("error signing token: %s", err)
+		}
+
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestValidateJWT(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := []byte("super-secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	validClaims := map[string]interface{}{
+		"sub": "user-1",
+		"iss": "https://auth.example.com",
+		"aud": []string{"my-api", "other-api"},
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+		"iat": now.Add(-time.Minute).Unix(),
+	}
+
+	baseConfig := func(key interface{}) middlewares.JWTConfig {
+		return middlewares.JWTConfig{
+			Audience:  "my-api",
+			ClockSkew: 30 * time.Second,
+			Issuer:    "https://auth.example.com",
+			Key:       key,
+			Now:       func() time.Time { return now },
+		}
+	}
+
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		result := map[string]interface{}{}
+
+		for k, v := range validClaims {
+			result[k] = v
+		}
+
+		result[name] = value
+		return result
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		config  middlewares.JWTConfig
+		wantErr error
+	}{
+		{
+			name:   "Accepts a valid HS256 token",
+			token:  signTestJWT(t, "HS256", "", secret, validClaims),
+			config: baseConfig(secret),
+		},
+		{
+			name:   "Accepts a valid RS256 token",
+			token:  signTestJWT(t, "RS256", "", rsaKey, validClaims),
+			config: baseConfig(&rsaKey.PublicKey),
+		},
+		{
+			name:   "Accepts a valid ES256 token",
+			token:  signTestJWT(t, "ES256", "", ecKey, validClaims),
+			config: baseConfig(&ecKey.PublicKey),
+		},
+		{
+			name:    "Rejects a token signed with another secret",
+			token:   signTestJWT(t, "HS256", "", []byte("wrong"), validClaims),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenSignatureInvalid,
+		},
+		{
+			name:    "Rejects an HMAC token when configured with an RSA key",
+			token:   signTestJWT(t, "HS256", "", secret, validClaims),
+			config:  baseConfig(&rsaKey.PublicKey),
+			wantErr: middlewares.ErrTokenInvalidKey,
+		},
+		{
+			name:  "Rejects algorithms that are not allowed",
+			token: signTestJWT(t, "HS256", "", secret, validClaims),
+			config: func() middlewares.JWTConfig {
+				c := baseConfig(secret)
+				c.Algorithms = []string{"RS256"}
+				return c
+			}(),
+			wantErr: middlewares.ErrTokenUnsupportedAlgorithm,
+		},
+		{
+			name:    "Rejects expired tokens",
+			token:   signTestJWT(t, "HS256", "", secret, withClaim("exp", now.Add(-time.Minute).Unix())),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenExpired,
+		},
+		{
+			name:   "Accepts recently expired tokens within the clock skew",
+			token:  signTestJWT(t, "HS256", "", secret, withClaim("exp", now.Add(-10*time.Second).Unix())),
+			config: baseConfig(secret),
+		},
+		{
+			name:   "Accepts expiry dates far in the future",
+			token:  signTestJWT(t, "HS256", "", secret, withClaim("exp", int64(9999999999))),
+			config: baseConfig(secret),
+		},
+		{
+			name:   "Accepts fractional expiry dates",
+			token:  signTestJWT(t, "HS256", "", secret, withClaim("exp", float64(now.Unix())+0.5)),
+			config: baseConfig(secret),
+		},
+		{
+			name:    "Rejects expiry dates out of range",
+			token:   signTestJWT(t, "HS256", "", secret, withClaim("exp", 1e300)),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenMalformed,
+		},
+		{
+			name:    "Rejects tokens that are not valid yet",
+			token:   signTestJWT(t, "HS256", "", secret, withClaim("nbf", now.Add(time.Minute).Unix())),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenNotValidYet,
+		},
+		{
+			name:    "Rejects tokens issued in the future",
+			token:   signTestJWT(t, "HS256", "", secret, withClaim("iat", now.Add(time.Minute).Unix())),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenUsedBeforeIssued,
+		},
+		{
+			name:    "Rejects tokens from another issuer",
+			token:   signTestJWT(t, "HS256", "", secret, withClaim("iss", "https://evil.example.com")),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenInvalidIssuer,
+		},
+		{
+			name:    "Rejects tokens for another audience",
+			token:   signTestJWT(t, "HS256", "", secret, withClaim("aud", "other-api")),
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenInvalidAudience,
+		},
+		{
+			name:    "Rejects malformed tokens",
+			token:   "not.a-token",
+			config:  baseConfig(secret),
+			wantErr: middlewares.ErrTokenMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := middlewares.ValidateJWT(tt.token, tt.config)
+
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("did not expect an error, got %s", err)
+				}
+
+				if claims.Subject != "user-1" {
+					t.Errorf("wanted subject user-1, got %s", claims.Subject)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("wanted error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := []byte("super-secret")
+	logger := middlewares.NewLogrusLogger(logrus.New().WithField("who", "testing"))
+	config := middlewares.JWTConfig{Algorithms: []string{"HS256"}, Key: secret}
+
+	token := signTestJWT(t, "HS256", "", secret, map[string]interface{}{
+		"sub":  "user-1",
+		"role": "admin",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantInvalid   bool
+	}{
+		{
+			name:          "Stores claims for a valid token",
+			authorization: "Bearer " + token,
+			wantInvalid:   false,
+		},
+		{
+			name:          "Calls the error method for a missing header",
+			authorization: "",
+			wantInvalid:   true,
+		},
+		{
+			name:          "Calls the error method for an invalid token",
+			authorization: "Bearer " + token + "x",
+			wantInvalid:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotInvalid := false
+
+			handler := middlewares.VerifyJWT(func(w http.ResponseWriter, r *http.Request) {
+				claims, ok := middlewares.ClaimsFromContext(r)
+
+				if !ok {
+					t.Fatalf("expected claims in the context")
+				}
+
+				custom := struct {
+					Role string `json:"role"`
+				}{}
+
+				if err := claims.Decode(&custom); err != nil || custom.Role != "admin" {
+					t.Errorf("wanted role admin, got '%s' (%v)", custom.Role, err)
+				}
+			}, logger, config, func(logger middlewares.Logger, w http.ResponseWriter) {
+				gotInvalid = true
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", tt.authorization)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if gotInvalid != tt.wantInvalid {
+				t.Errorf("wanted invalid %v, got %v", tt.wantInvalid, gotInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"log/slog"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+LogFields are structured fields added to a log entry.
+*/
+type LogFields map[string]interface{}
+
+/*
+Logger is the logging interface used by nerdweb and its middlewares.
+Adapters are provided for logrus (NewLogrusLogger), log/slog
+(NewSlogLogger), and for discarding logs (NewNopLogger).
+*/
+type Logger interface {
+	Debug(msg string)
+	Error(msg string)
+	Info(msg string)
+	Warn(msg string)
+	WithError(err error) Logger
+	WithFields(fields LogFields) Logger
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+/*
+NewLogrusLogger adapts a logrus entry to the Logger interface. It
+returns nil when entry is nil, so nerdweb functions can fall back
+to the request-scoped logger.
+*/
+func NewLogrusLogger(entry *logrus.Entry) Logger {
+	if entry == nil {
+		return nil
+	}
+
+	return &logrusLogger{entry: entry}
+}
+
+func (l *logrusLogger) Debug(msg string) { l.entry.Debug(msg) }
+func (l *logrusLogger) Error(msg string) { l.entry.Error(msg) }
+func (l *logrusLogger) Info(msg string)  { l.entry.Info(msg) }
+func (l *logrusLogger) Warn(msg string)  { l.entry.Warn(msg) }
+
+func (l *logrusLogger) WithError(err error) Logger {
+	return &logrusLogger{entry: l.entry.WithError(err)}
+}
+
+func (l *logrusLogger) WithFields(fields LogFields) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+/*
+NewSlogLogger adapts a log/slog logger to the Logger interface.
+*/
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		return nil
+	}
+
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Debug(msg string) { l.logger.Debug(msg) }
+func (l *slogLogger) Error(msg string) { l.logger.Error(msg) }
+func (l *slogLogger) Info(msg string)  { l.logger.Info(msg) }
+func (l *slogLogger) Warn(msg string)  { l.logger.Warn(msg) }
+
+func (l *slogLogger) WithError(err error) Logger {
+	return &slogLogger{logger: l.logger.With(slog.Any("error", err))}
+}
+
+func (l *slogLogger) WithFields(fields LogFields) Logger {
+	keys := make([]string, 0, len(fields))
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	args := make([]interface{}, 0, len(fields))
+
+	for _, key := range keys {
+		args = append(args, slog.Any(key, fields[key]))
+	}
+
+	return &slogLogger{logger: l.logger.With(args...)}
+}
+
+type nopLogger struct{}
+
+/*
+NewNopLogger returns a Logger that discards everything.
+*/
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(msg string)                     {}
+func (nopLogger) Error(msg string)                     {}
+func (nopLogger) Info(msg string)                      {}
+func (nopLogger) Warn(msg string)                      {}
+func (l nopLogger) WithError(err error) Logger         { return l }
+func (l nopLogger) WithFields(fields LogFields) Logger { return l }
+
+/*
+defaultLogger is used when no logger is provided. It writes to the
+logrus standard logger.
+*/
+func defaultLogger() Logger {
+	return NewLogrusLogger(logrus.NewEntry(logrus.StandardLogger()))
+}
@@ -0,0 +1,71 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoggerAdapters(t *testing.T) {
+	tests := []struct {
+		name   string
+		create func(output *bytes.Buffer) middlewares.Logger
+		want   []string
+	}{
+		{
+			name: "Logrus adapter writes fields and errors",
+			create: func(output *bytes.Buffer) middlewares.Logger {
+				logger := logrus.New()
+				logger.SetOutput(output)
+				return middlewares.NewLogrusLogger(logrus.NewEntry(logger))
+			},
+			want: []string{"level=warning", `msg="something happened"`, "id=4", "error=boom"},
+		},
+		{
+			name: "Slog adapter writes fields and errors",
+			create: func(output *bytes.Buffer) middlewares.Logger {
+				return middlewares.NewSlogLogger(slog.New(slog.NewTextHandler(output, nil)))
+			},
+			want: []string{"level=WARN", `msg="something happened"`, "id=4", "error=boom"},
+		},
+		{
+			name: "Nop adapter writes nothing",
+			create: func(output *bytes.Buffer) middlewares.Logger {
+				return middlewares.NewNopLogger()
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			logger := tt.create(output)
+
+			logger.WithError(errors.New("boom")).WithFields(middlewares.LogFields{"id": 4}).Warn("something happened")
+
+			got := output.String()
+
+			if len(tt.want) == 0 && got != "" {
+				t.Errorf("wanted no output, got %s", got)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected '%s' in log output: %s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewLogrusLoggerNil(t *testing.T) {
+	if got := middlewares.NewLogrusLogger(nil); got != nil {
+		t.Errorf("wanted nil, got %v", got)
+	}
+}
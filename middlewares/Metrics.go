@@ -0,0 +1,307 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+DefaultLatencyBuckets are the upper bounds, in seconds, of the request
+duration histogram buckets.
+*/
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+/*
+DefaultSizeBuckets are the upper bounds, in bytes, of the response size
+histogram buckets.
+*/
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+/*
+MetricsOptions configures a Metrics collector.
+
+Namespace is prepended to every metric name, separated by an underscore.
+LatencyBuckets and SizeBuckets are the histogram bucket upper bounds and
+default to DefaultLatencyBuckets and DefaultSizeBuckets.
+*/
+type MetricsOptions struct {
+	LatencyBuckets []float64
+	Namespace      string
+	SizeBuckets    []float64
+}
+
+type metricsKey struct {
+	method string
+	route  string
+	status string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+func (h *histogram) observe(buckets []float64, value float64) {
+	for index, upperBound := range buckets {
+		if value <= upperBound {
+			h.counts[index]++
+		}
+	}
+
+	h.sum += value
+}
+
+type metricsSeries struct {
+	count    uint64
+	duration histogram
+	size     histogram
+}
+
+/*
+Metrics collects request counts, in-flight requests, request durations,
+and response sizes, labelled by method, route template, and status
+class. Use Middleware to record requests and Handler to expose the
+metrics in the Prometheus text exposition format.
+*/
+type Metrics struct {
+	inFlight int64
+	lock     sync.Mutex
+	options  MetricsOptions
+	series   map[metricsKey]*metricsSeries
+}
+
+/*
+NewMetrics creates a metrics collector.
+
+Example:
+
+  metrics := middlewares.NewMetrics(middlewares.MetricsOptions{Namespace: "myapp"})
+
+  router.Use(metrics.Middleware())
+  router.Handle("/metrics", metrics.Handler())
+*/
+func NewMetrics(options MetricsOptions) *Metrics {
+	if options.LatencyBuckets == nil {
+		options.LatencyBuckets = DefaultLatencyBuckets
+	}
+
+	if options.SizeBuckets == nil {
+		options.SizeBuckets = DefaultSizeBuckets
+	}
+
+	options.LatencyBuckets = sortedBuckets(options.LatencyBuckets)
+	options.SizeBuckets = sortedBuckets(options.SizeBuckets)
+
+	return &Metrics{
+		options: options,
+		series:  map[metricsKey]*metricsSeries{},
+	}
+}
+
+type metricsRecorder struct {
+	handler http.Handler
+	metrics *Metrics
+}
+
+func (m *metricsRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.metrics.inFlight, 1)
+	defer atomic.AddInt64(&m.metrics.inFlight, -1)
+
+	wrapped, recorder := WrapResponseWriter(w)
+	startTime := time.Now()
+
+	defer func() {
+		m.metrics.observe(r, recorder, time.Since(startTime))
+	}()
+
+	m.handler.ServeHTTP(wrapped, r)
+}
+
+/*
+Middleware returns a middleware that records every request. Requests
+are labelled with the matched route template rather than the raw path,
+so install it with router.Use. Gorilla Mux does not run middlewares for
+requests that match no route, so also wrap the router's NotFoundHandler
+and MethodNotAllowedHandler to count those under the route "unmatched".
+Servers created by nerdweb do this for you.
+*/
+func (m *Metrics) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := &metricsRecorder{
+				handler: next,
+				metrics: m,
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+/*
+Handler returns a handler that writes the metrics in the Prometheus
+text exposition format.
+*/
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(m.exposition())
+	})
+}
+
+func (m *Metrics) observe(r *http.Request, recorder *ResponseRecorder, duration time.Duration) {
+	key := metricsKey{
+		method: metricsMethod(r.Method),
+		route:  "unmatched",
+		status: strconv.Itoa(recorder.Status/100) + "xx",
+	}
+
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			key.route = template
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	series, ok := m.series[key]
+
+	if !ok {
+		series = &metricsSeries{
+			duration: histogram{counts: make([]uint64, len(m.options.LatencyBuckets))},
+			size:     histogram{counts: make([]uint64, len(m.options.SizeBuckets))},
+		}
+
+		m.series[key] = series
+	}
+
+	series.count++
+	series.duration.observe(m.options.LatencyBuckets, duration.Seconds())
+	series.size.observe(m.options.SizeBuckets, float64(recorder.Bytes))
+}
+
+func (m *Metrics) exposition() []byte {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := make([]metricsKey, 0, len(m.series))
+
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+
+		return keys[i].status < keys[j].status
+	})
+
+	b := &bytes.Buffer{}
+
+	name := m.metricName("http_requests_total")
+	fmt.Fprintf(b, "# HELP %s Total number of HTTP requests.\n# TYPE %s counter\n", name, name)
+
+	for _, key := range keys {
+		fmt.Fprintf(b, "%s%s %d\n", name, key.labels(""), m.series[key].count)
+	}
+
+	name = m.metricName("http_requests_in_flight")
+	fmt.Fprintf(b, "# HELP %s Number of HTTP requests being served.\n# TYPE %s gauge\n", name, name)
+	fmt.Fprintf(b, "%s %d\n", name, atomic.LoadInt64(&m.inFlight))
+
+	name = m.metricName("http_request_duration_seconds")
+	fmt.Fprintf(b, "# HELP %s Duration of HTTP requests in seconds.\n# TYPE %s histogram\n", name, name)
+
+	for _, key := range keys {
+		series := m.series[key]
+		writeHistogram(b, name, key, m.options.LatencyBuckets, series.duration, series.count)
+	}
+
+	name = m.metricName("http_response_size_bytes")
+	fmt.Fprintf(b, "# HELP %s Size of HTTP response bodies in bytes.\n# TYPE %s histogram\n", name, name)
+
+	for _, key := range keys {
+		series := m.series[key]
+		writeHistogram(b, name, key, m.options.SizeBuckets, series.size, series.count)
+	}
+
+	return b.Bytes()
+}
+
+func (m *Metrics) metricName(name string) string {
+	if m.options.Namespace == "" {
+		return name
+	}
+
+	return m.options.Namespace + "_" + name
+}
+
+func (k metricsKey) labels(le string) string {
+	result := fmt.Sprintf(`{method="%s",route="%s",status="%s"`,
+		escapeLabelValue(k.method),
+		escapeLabelValue(k.route),
+		escapeLabelValue(k.status),
+	)
+
+	if le != "" {
+		result += `,le="` + le + `"`
+	}
+
+	return result + "}"
+}
+
+func writeHistogram(b *bytes.Buffer, name string, key metricsKey, buckets []float64, h histogram, count uint64) {
+	for index, upperBound := range buckets {
+		fmt.Fprintf(b, "%s_bucket%s %d\n", name, key.labels(formatFloat(upperBound)), h.counts[index])
+	}
+
+	fmt.Fprintf(b, "%s_bucket%s %d\n", name, key.labels("+Inf"), count)
+	fmt.Fprintf(b, "%s_sum%s %s\n", name, key.labels(""), formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, key.labels(""), count)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+/*
+metricsMethod keeps the method label to a known set, so requests with
+made up methods cannot create new series.
+*/
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead,
+		http.MethodOptions, http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+func sortedBuckets(buckets []float64) []float64 {
+	result := append([]float64{}, buckets...)
+	sort.Float64s(result)
+	return result
+}
@@ -0,0 +1,78 @@
+package middlewares_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/gorilla/mux"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := middlewares.NewMetrics(middlewares.MetricsOptions{
+		Namespace:      "test",
+		LatencyBuckets: []float64{1, 0.1},
+		SizeBuckets:    []float64{10, 100},
+	})
+
+	router := mux.NewRouter()
+	router.Use(metrics.Middleware())
+
+	router.HandleFunc("/widgets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("widget data"))
+	})
+
+	router.Handle("/metrics", metrics.Handler())
+
+	for _, path := range []string{"/widgets/1", "/widgets/2", "/widgets/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	r := httptest.NewRequest("BREW", "/widgets/3", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	got := string(body)
+
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("wanted Prometheus text content type, got %s", contentType)
+	}
+
+	wants := []string{
+		"# TYPE test_http_requests_total counter",
+		`test_http_requests_total{method="GET",route="/widgets/{id}",status="2xx"} 2`,
+		`test_http_requests_total{method="GET",route="/widgets/{id}",status="4xx"} 1`,
+		`test_http_requests_total{method="OTHER",route="/widgets/{id}",status="2xx"} 1`,
+		"# TYPE test_http_requests_in_flight gauge",
+		"test_http_requests_in_flight 1",
+		"# TYPE test_http_request_duration_seconds histogram",
+		`test_http_request_duration_seconds_bucket{method="GET",route="/widgets/{id}",status="2xx",le="0.1"} 2`,
+		`test_http_request_duration_seconds_bucket{method="GET",route="/widgets/{id}",status="2xx",le="+Inf"} 2`,
+		`test_http_request_duration_seconds_count{method="GET",route="/widgets/{id}",status="2xx"} 2`,
+		"# TYPE test_http_response_size_bytes histogram",
+		`test_http_response_size_bytes_bucket{method="GET",route="/widgets/{id}",status="2xx",le="10"} 0`,
+		`test_http_response_size_bytes_bucket{method="GET",route="/widgets/{id}",status="2xx",le="100"} 2`,
+		`test_http_response_size_bytes_sum{method="GET",route="/widgets/{id}",status="2xx"} 22`,
+		`test_http_response_size_bytes_bucket{method="GET",route="/widgets/{id}",status="4xx",le="10"} 1`,
+	}
+
+	for _, want := range wants {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected '%s' in metrics output:\n%s", want, got)
+		}
+	}
+
+	if strings.Contains(got, "/widgets/1") {
+		t.Errorf("expected raw paths not to be used as labels:\n%s", got)
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+writeProblem writes an RFC 7807 problem response. It matches the
+output of nerdweb.WriteProblem, which this package cannot import.
+*/
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": detail,
+	})
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
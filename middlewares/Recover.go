@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+RecoverOptions configures the Recover middleware.
+
+DisableStackTrace stops the stack trace from being logged. OnPanic, when
+set, is called after the panic is logged, for example to report it to
+an error tracking service.
+*/
+type RecoverOptions struct {
+	DisableStackTrace bool
+	OnPanic           func(r *http.Request, recovered interface{})
+}
+
+type recoverer struct {
+	handler http.Handler
+	logger  Logger
+	options RecoverOptions
+}
+
+func (m *recoverer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wrapped, recorder := WrapResponseWriter(w)
+
+	defer func() {
+		recovered := recover()
+
+		if recovered == nil {
+			return
+		}
+
+		if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			panic(recovered)
+		}
+
+		fields := LogFields{
+			"ip":     realIP(r),
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"panic":  fmt.Sprintf("%v", recovered),
+		}
+
+		if !m.options.DisableStackTrace {
+			fields["stack"] = string(debug.Stack())
+		}
+
+		WithRequestFields(m.logger, r).WithFields(fields).Error("recovered from panic")
+
+		if m.options.OnPanic != nil {
+			m.options.OnPanic(r, recovered)
+		}
+
+		if !recorder.WroteHeader && !recorder.Hijacked {
+			writeProblem(w, http.StatusInternalServerError, "an unexpected error occurred")
+		}
+	}()
+
+	m.handler.ServeHTTP(wrapped, r)
+}
+
+/*
+Recover returns a middleware that recovers from panics in handlers.
+The panic, stack trace, and request details are logged, and a 500
+problem response is written if the handler has not already started
+writing a response. Panics with http.ErrAbortHandler are re-raised
+so the server can abort the response.
+
+Example:
+
+  mux := nerdweb.NewServeMux()
+  mux.HandleFunc("/endpoint", handler)
+
+  mux.Use(middlewares.Recover(logger, middlewares.RecoverOptions{}))
+*/
+func Recover(logger Logger, options RecoverOptions) mux.MiddlewareFunc {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := &recoverer{
+				handler: next,
+				logger:  logger,
+				options: options,
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
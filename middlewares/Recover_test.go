@@ -0,0 +1,88 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "Writes a 500 problem when the handler panics",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"detail":"an unexpected error occurred","status":500,"title":"Internal Server Error","type":"about:blank"}`,
+		},
+		{
+			name: "Does not write a response when headers were already sent",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("partial"))
+				panic("boom")
+			},
+			wantStatus: http.StatusAccepted,
+			wantBody:   "partial",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			logger := logrus.New()
+			logger.SetOutput(output)
+
+			var gotPanic interface{}
+
+			handler := middlewares.Recover(middlewares.NewLogrusLogger(logrus.NewEntry(logger)), middlewares.RecoverOptions{
+				OnPanic: func(r *http.Request, recovered interface{}) { gotPanic = recovered },
+			})(tt.handler)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/explode", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("wanted status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("want: %s\ngot: %s", tt.wantBody, w.Body.String())
+			}
+
+			if gotPanic != "boom" {
+				t.Errorf("wanted OnPanic to receive 'boom', got %v", gotPanic)
+			}
+
+			if !strings.Contains(output.String(), "recovered from panic") || !strings.Contains(output.String(), "stack=") {
+				t.Errorf("expected the panic and stack to be logged, got %s", output.String())
+			}
+		})
+	}
+}
+
+func TestRecoverRepanicsOnAbortHandler(t *testing.T) {
+	logger := logrus.New()
+	handler := middlewares.Recover(middlewares.NewLogrusLogger(logrus.NewEntry(logger)), middlewares.RecoverOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("wanted http.ErrAbortHandler to be re-panicked, got %v", recovered)
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,184 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	DefaultRequestIDHeader string = "X-Request-ID"
+	maxRequestIDLength     int    = 128
+)
+
+/*
+RequestIDOptions configures the RequestID middleware.
+
+HeaderName is the header read from the request and written to the
+response. Defaults to "X-Request-ID". Generator creates new IDs and
+defaults to NewUUIDv4. Validate decides if an incoming ID may be used;
+by default IDs must be 1 to 128 characters of letters, digits, and
+"-", "_", ".", or ":". Set IgnoreIncoming to always generate a new ID.
+*/
+type RequestIDOptions struct {
+	Generator      func() string
+	HeaderName     string
+	IgnoreIncoming bool
+	Validate       func(id string) bool
+}
+
+type requestID struct {
+	handler http.Handler
+	options RequestIDOptions
+}
+
+func (m *requestID) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := ""
+
+	if !m.options.IgnoreIncoming {
+		id = r.Header.Get(m.options.HeaderName)
+	}
+
+	if id == "" || !m.options.Validate(id) {
+		id = m.options.Generator()
+	}
+
+	w.Header().Set(m.options.HeaderName, id)
+
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	r = r.WithContext(ctx)
+	m.handler.ServeHTTP(w, r)
+}
+
+/*
+RequestID returns a middleware that gives every request an ID. An
+incoming ID is used when it is valid, otherwise a new one is generated.
+The ID is stored in the request context, echoed in the response header,
+and included in RequestLogger output. Use RequestIDFromContext to read
+it. Install this middleware before RequestLogger.
+
+Example:
+
+  mux.Use(middlewares.RequestID(middlewares.RequestIDOptions{}))
+  mux.Use(middlewares.RequestLogger(logger))
+*/
+func RequestID(options RequestIDOptions) mux.MiddlewareFunc {
+	if options.Generator == nil {
+		options.Generator = NewUUIDv4
+	}
+
+	if options.HeaderName == "" {
+		options.HeaderName = DefaultRequestIDHeader
+	}
+
+	if options.Validate == nil {
+		options.Validate = isValidRequestID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := &requestID{
+				handler: next,
+				options: options,
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+/*
+RequestIDFromContext returns the request ID stored by RequestID. The
+second return value is false if there is no request ID.
+*/
+func RequestIDFromContext(r *http.Request) (string, bool) {
+	id, ok := r.Context().Value(requestIDContextKey).(string)
+	return id, ok
+}
+
+/*
+WithRequestFields returns a logger derived from logger that includes
+the request's ID, when it has one.
+*/
+func WithRequestFields(logger Logger, r *http.Request) Logger {
+	if id, ok := RequestIDFromContext(r); ok {
+		return logger.WithFields(LogFields{"requestID": id})
+	}
+
+	return logger
+}
+
+/*
+NewUUIDv4 returns a random (version 4) UUID.
+*/
+func NewUUIDv4() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	result := make([]byte, 36)
+	hex.Encode(result[0:8], b[0:4])
+	result[8] = '-'
+	hex.Encode(result[9:13], b[4:6])
+	result[13] = '-'
+	hex.Encode(result[14:18], b[6:8])
+	result[18] = '-'
+	hex.Encode(result[19:23], b[8:10])
+	result[23] = '-'
+	hex.Encode(result[24:], b[10:])
+
+	return string(result)
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+/*
+NewULID returns a ULID, a lexicographically sortable identifier made
+of a millisecond timestamp and 80 random bits.
+*/
+func NewULID() string {
+	var b [16]byte
+
+	binary.BigEndian.PutUint64(b[0:8], uint64(time.Now().UnixMilli())<<16)
+	_, _ = rand.Read(b[6:])
+
+	/*
+	 * Encode 128 bits as 26 base32 characters. The first character
+	 * only carries 3 bits.
+	 */
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+	result := make([]byte, 26)
+
+	for i := 25; i >= 0; i-- {
+		result[i] = crockfordAlphabet[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+
+	return string(result)
+}
+
+func isValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
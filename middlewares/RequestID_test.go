@@ -0,0 +1,117 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+var uuidV4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    middlewares.RequestIDOptions
+		header     string
+		incoming   string
+		wantID     string
+		wantFormat *regexp.Regexp
+	}{
+		{
+			name:     "Uses a valid incoming ID",
+			header:   "X-Request-ID",
+			incoming: "abc-123",
+			wantID:   "abc-123",
+		},
+		{
+			name:       "Generates a UUID when the incoming ID is invalid",
+			header:     "X-Request-ID",
+			incoming:   "bad id\n",
+			wantFormat: uuidV4Pattern,
+		},
+		{
+			name:       "Generates a UUID when there is no incoming ID",
+			header:     "X-Request-ID",
+			wantFormat: uuidV4Pattern,
+		},
+		{
+			name:     "Uses a configurable header name",
+			options:  middlewares.RequestIDOptions{HeaderName: "X-Correlation-ID"},
+			header:   "X-Correlation-ID",
+			incoming: "correlation-1",
+			wantID:   "correlation-1",
+		},
+		{
+			name:       "Ignores incoming IDs when configured",
+			options:    middlewares.RequestIDOptions{IgnoreIncoming: true, Generator: middlewares.NewULID},
+			header:     "X-Request-ID",
+			incoming:   "abc-123",
+			wantFormat: regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotContextID := ""
+
+			handler := middlewares.RequestID(tt.options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotContextID, _ = middlewares.RequestIDFromContext(r)
+			}))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tt.incoming != "" {
+				r.Header.Set(tt.header, tt.incoming)
+			}
+
+			handler.ServeHTTP(w, r)
+			gotHeaderID := w.Header().Get(tt.header)
+
+			if gotHeaderID != gotContextID {
+				t.Errorf("wanted response header '%s' to match context ID '%s'", gotHeaderID, gotContextID)
+			}
+
+			if tt.wantID != "" && gotContextID != tt.wantID {
+				t.Errorf("wanted ID '%s', got '%s'", tt.wantID, gotContextID)
+			}
+
+			if tt.wantFormat != nil && !tt.wantFormat.MatchString(gotContextID) {
+				t.Errorf("ID '%s' does not match %s", gotContextID, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestRequestIDInRequestLogger(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(output)
+
+	handler := middlewares.RequestID(middlewares.RequestIDOptions{})(
+		middlewares.RequestLogger(logrus.NewEntry(logger))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "trace-me")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(output.String(), "requestID=trace-me") {
+		t.Errorf("expected the request ID to be logged, got %s", output.String())
+	}
+}
+
+func TestNewULIDIsSortable(t *testing.T) {
+	first := middlewares.NewULID()
+	second := middlewares.NewULID()
+
+	if len(first) != 26 || first[:10] > second[:10] {
+		t.Errorf("expected time ordered ULIDs, got %s then %s", first, second)
+	}
+}
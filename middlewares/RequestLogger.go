@@ -1,41 +1,292 @@
 package middlewares
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
+/*
+LogFormat selects how RequestLogger writes each request.
+LogFormatFields logs through the Logger with structured fields,
+LogFormatCombined writes Apache Combined Log Format lines, and
+LogFormatJSON writes one JSON object per line.
+*/
+type LogFormat int
+
+const (
+	LogFormatFields LogFormat = iota
+	LogFormatCombined
+	LogFormatJSON
+)
+
+/*
+DefaultRedactedQueryParams are the query parameters RequestLogger
+redacts when RequestLoggerOptions.RedactQueryParams is nil.
+*/
+var DefaultRedactedQueryParams = []string{"token", "password", "api_key"}
+
+/*
+RequestLoggerOptions configures the RequestLogger middleware.
+
+Format selects the output format and defaults to LogFormatFields, which
+logs through the Logger. The Combined and JSON formats write one line
+per request to Output, which defaults to os.Stdout.
+
+Requests whose path starts with one of SkipPaths, or matches one of
+SkipPathPatterns, are not logged. SampleRate logs only that fraction
+(between 0 and 1) of successful requests; requests with a 4xx or 5xx
+status are always logged. Zero logs every request. Requests that take
+longer than SlowThreshold are always logged, at the warning level.
+
+The values of the query parameters named in RedactQueryParams are
+replaced before the query is logged. Names are not case sensitive.
+Defaults to DefaultRedactedQueryParams; use an empty slice to turn
+redaction off.
+
+IPResolver decides which proxies are trusted when finding the caller's
+IP address. When nil the one installed by TrustedProxies is used.
+*/
+type RequestLoggerOptions struct {
+	Format            LogFormat
+	IPResolver        *IPResolver
+	Output            io.Writer
+	RedactQueryParams []string
+	SampleRate        float64
+	SkipPathPatterns  []*regexp.Regexp
+	SkipPaths         []string
+	SlowThreshold     time.Duration
+}
+
+/*
+requestLogEntry is everything RequestLogger knows about a finished
+request. It is also the shape of a JSON log line.
+*/
+type requestLogEntry struct {
+	ExecutionTime string    `json:"executionTime"`
+	IP            string    `json:"ip"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Protocol      string    `json:"protocol"`
+	QueryParams   string    `json:"queryParams,omitempty"`
+	Referer       string    `json:"referer,omitempty"`
+	RequestID     string    `json:"requestID,omitempty"`
+	Route         string    `json:"route,omitempty"`
+	Size          int64     `json:"size"`
+	Slow          bool      `json:"slow,omitempty"`
+	Status        int       `json:"status"`
+	Time          time.Time `json:"time"`
+	UserAgent     string    `json:"userAgent,omitempty"`
+
+	requestURI string
+	user       string
+}
+
 type requestLogger struct {
 	handler http.Handler
-	logger  *logrus.Entry
+	lock    *sync.Mutex
+	logger  Logger
+	options RequestLoggerOptions
 }
 
 func (m *requestLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	recorder := &statusRecorder{
-		ResponseWriter: w,
-		Status:         http.StatusOK,
-	}
+	wrapped, recorder := WrapResponseWriter(w)
 
 	startTime := time.Now()
-	ip := realIP(r)
+	ip := resolveIP(m.options.IPResolver, r)
 
-	m.handler.ServeHTTP(recorder, r)
+	m.handler.ServeHTTP(wrapped, r)
 	diff := time.Since(startTime)
 
-	m.logger.WithFields(logrus.Fields{
-		"ip":            ip,
-		"method":        r.Method,
-		"status":        recorder.Status,
-		"executionTime": diff,
-		"queryParams":   r.URL.RawQuery,
-	}).Info(r.URL.Path)
+	if m.skip(r) {
+		return
+	}
+
+	slow := m.options.SlowThreshold > 0 && diff > m.options.SlowThreshold
+
+	if !slow && !m.sampled(recorder.Status) {
+		return
+	}
+
+	query := redactQuery(r.URL.RawQuery, m.options.RedactQueryParams)
+
+	entry := requestLogEntry{
+		ExecutionTime: diff.String(),
+		IP:            ip,
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Protocol:      r.Proto,
+		QueryParams:   query,
+		Referer:       r.Referer(),
+		Size:          recorder.Bytes,
+		Slow:          slow,
+		Status:        recorder.Status,
+		Time:          startTime,
+		UserAgent:     r.UserAgent(),
+		requestURI:    r.URL.EscapedPath(),
+	}
+
+	if query != "" {
+		entry.requestURI += "?" + query
+	}
+
+	if id, ok := RequestIDFromContext(r); ok {
+		entry.RequestID = id
+	}
+
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			entry.Route = template
+		}
+	}
+
+	if user, _, ok := r.BasicAuth(); ok {
+		entry.user = user
+	}
+
+	switch m.options.Format {
+	case LogFormatCombined:
+		m.writeLine(formatCombined(entry))
+	case LogFormatJSON:
+		b, _ := json.Marshal(entry)
+		m.writeLine(string(b) + "\n")
+	default:
+		fields := LogFields{
+			"ip":            entry.IP,
+			"method":        entry.Method,
+			"status":        entry.Status,
+			"executionTime": diff,
+			"queryParams":   entry.QueryParams,
+			"protocol":      entry.Protocol,
+			"size":          entry.Size,
+			"userAgent":     entry.UserAgent,
+			"referer":       entry.Referer,
+		}
+
+		if entry.Route != "" {
+			fields["route"] = entry.Route
+		}
+
+		logger := WithRequestFields(m.logger, r).WithFields(fields)
+
+		if slow {
+			logger.Warn(r.URL.Path)
+			return
+		}
+
+		logger.Info(r.URL.Path)
+	}
+}
+
+func (m *requestLogger) skip(r *http.Request) bool {
+	for _, prefix := range m.options.SkipPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	for _, pattern := range m.options.SkipPathPatterns {
+		if pattern.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *requestLogger) sampled(status int) bool {
+	if status >= 400 || m.options.SampleRate <= 0 || m.options.SampleRate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < m.options.SampleRate
+}
+
+/*
+redactQuery replaces the values of the named query parameters,
+keeping the rest of the query as it was sent.
+*/
+func redactQuery(rawQuery string, names []string) string {
+	if rawQuery == "" || len(names) == 0 {
+		return rawQuery
+	}
+
+	parts := strings.Split(rawQuery, "&")
+
+	for index, part := range parts {
+		rawKey, _, hasValue := strings.Cut(part, "=")
+		key := rawKey
+
+		if unescaped, err := url.QueryUnescape(rawKey); err == nil {
+			key = unescaped
+		}
+
+		for _, name := range names {
+			if hasValue && strings.EqualFold(key, name) {
+				parts[index] = rawKey + "=REDACTED"
+				break
+			}
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func (m *requestLogger) writeLine(line string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, _ = io.WriteString(m.options.Output, line)
 }
 
 /*
-RequestLogger returns a middleware for logging all requests.
+formatCombined formats an entry in the Apache Combined Log Format:
+
+  %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+*/
+func formatCombined(entry requestLogEntry) string {
+	dashIfEmpty := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+
+		return value
+	}
+
+	size := "-"
+
+	if entry.Size > 0 {
+		size = strconv.FormatInt(entry.Size, 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %s %d %s %s %s\n",
+		dashIfEmpty(entry.IP),
+		dashIfEmpty(entry.user),
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(entry.Method+" "+entry.requestURI+" "+entry.Protocol),
+		entry.Status,
+		size,
+		strconv.Quote(dashIfEmpty(entry.Referer)),
+		strconv.Quote(dashIfEmpty(entry.UserAgent)),
+	)
+}
+
+/*
+RequestLogger returns a middleware for logging all requests
+using a logrus entry. See RequestLoggerWith for other loggers
+and output formats.
 
 Example:
 
@@ -45,11 +296,47 @@ Example:
   mux.Use(middlewares.RequestLogger(logger))
 */
 func RequestLogger(logger *logrus.Entry) mux.MiddlewareFunc {
+	return RequestLoggerWith(NewLogrusLogger(logger), RequestLoggerOptions{})
+}
+
+/*
+RequestLoggerWith returns a middleware for logging all requests. Each
+request is logged with its IP address, method, path, query, protocol,
+status, response size, execution time, user agent, referer, route
+template, and request ID. When logger is nil the logrus standard logger
+is used. See RequestLoggerOptions to skip, sample, or redact requests.
+
+Example:
+
+  mux.Use(middlewares.RequestLoggerWith(middlewares.NewSlogLogger(slog.Default()), middlewares.RequestLoggerOptions{}))
+
+  mux.Use(middlewares.RequestLoggerWith(nil, middlewares.RequestLoggerOptions{
+    Format: middlewares.LogFormatCombined,
+    Output: accessLog,
+  }))
+*/
+func RequestLoggerWith(logger Logger, options RequestLoggerOptions) mux.MiddlewareFunc {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+
+	if options.Output == nil {
+		options.Output = os.Stdout
+	}
+
+	if options.RedactQueryParams == nil {
+		options.RedactQueryParams = DefaultRedactedQueryParams
+	}
+
+	lock := &sync.Mutex{}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			handler := &requestLogger{
 				handler: next,
+				lock:    lock,
 				logger:  logger,
+				options: options,
 			}
 
 			handler.ServeHTTP(w, r)
@@ -0,0 +1,246 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func newRequestLoggerRouter(middleware mux.MiddlewareFunc) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(middlewares.RequestID(middlewares.RequestIDOptions{}))
+	router.Use(middleware)
+
+	router.HandleFunc("/widget/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+		_, _ = w.Write([]byte(" world"))
+	})
+
+	return router
+}
+
+func newRequestLoggerRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/widget/4?color=blue", nil)
+	r.RemoteAddr = "203.0.113.9:1234"
+	r.Header.Set("User-Agent", "test-agent/1.0")
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("X-Request-ID", "req-1")
+	r.SetBasicAuth("adam", "secret")
+	return r
+}
+
+func TestRequestLoggerFields(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(output)
+
+	router := newRequestLoggerRouter(middlewares.RequestLogger(logrus.NewEntry(logger)))
+	router.ServeHTTP(httptest.NewRecorder(), newRequestLoggerRequest())
+
+	wants := []string{
+		"ip=203.0.113.9",
+		"method=POST",
+		"status=201",
+		"size=11",
+		`userAgent=test-agent/1.0`,
+		`referer="https://example.com/"`,
+		"protocol=HTTP/1.1",
+		`route="/widget/{id}"`,
+		"requestID=req-1",
+		`queryParams="color=blue"`,
+		"msg=/widget/4",
+	}
+
+	for _, want := range wants {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("expected '%s' in log output: %s", want, output.String())
+		}
+	}
+}
+
+func TestRequestLoggerFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format middlewares.LogFormat
+		check  func(t *testing.T, line string)
+	}{
+		{
+			name:   "Writes Apache Combined Log Format",
+			format: middlewares.LogFormatCombined,
+			check: func(t *testing.T, line string) {
+				want := regexp.MustCompile(`^203\.0\.113\.9 - adam \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /widget/4\?color=blue HTTP/1\.1" 201 11 "https://example\.com/" "test-agent/1\.0"\n$`)
+
+				if !want.MatchString(line) {
+					t.Errorf("wanted combined log line, got %q", line)
+				}
+			},
+		},
+		{
+			name:   "Writes JSON lines",
+			format: middlewares.LogFormatJSON,
+			check: func(t *testing.T, line string) {
+				got := map[string]interface{}{}
+
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatalf("expected valid JSON, got %q: %v", line, err)
+				}
+
+				wants := map[string]interface{}{
+					"ip":          "203.0.113.9",
+					"method":      "POST",
+					"path":        "/widget/4",
+					"protocol":    "HTTP/1.1",
+					"queryParams": "color=blue",
+					"referer":     "https://example.com/",
+					"requestID":   "req-1",
+					"route":       "/widget/{id}",
+					"size":        float64(11),
+					"status":      float64(201),
+					"userAgent":   "test-agent/1.0",
+				}
+
+				for key, want := range wants {
+					if got[key] != want {
+						t.Errorf("wanted %s %v, got %v", key, want, got[key])
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+
+			router := newRequestLoggerRouter(middlewares.RequestLoggerWith(middlewares.NewNopLogger(), middlewares.RequestLoggerOptions{
+				Format: tt.format,
+				Output: output,
+			}))
+
+			router.ServeHTTP(httptest.NewRecorder(), newRequestLoggerRequest())
+			tt.check(t, output.String())
+		})
+	}
+}
+
+func TestRequestLoggerFiltering(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   middlewares.RequestLoggerOptions
+		path      string
+		status    int
+		delay     time.Duration
+		wantLines int
+		want      string
+	}{
+		{
+			name:      "Skips paths by prefix",
+			options:   middlewares.RequestLoggerOptions{SkipPaths: []string{"/healthz"}},
+			path:      "/healthz/live",
+			status:    http.StatusOK,
+			wantLines: 0,
+		},
+		{
+			name:      "Skips paths by pattern",
+			options:   middlewares.RequestLoggerOptions{SkipPathPatterns: []*regexp.Regexp{regexp.MustCompile(`\.(css|js)$`)}},
+			path:      "/static/app.js",
+			status:    http.StatusOK,
+			wantLines: 0,
+		},
+		{
+			name:      "Does not skip other paths",
+			options:   middlewares.RequestLoggerOptions{SkipPaths: []string{"/healthz"}},
+			path:      "/widgets",
+			status:    http.StatusOK,
+			wantLines: 1,
+		},
+		{
+			name:      "Samples out successful requests",
+			options:   middlewares.RequestLoggerOptions{SampleRate: 0.0000001},
+			path:      "/widgets",
+			status:    http.StatusOK,
+			wantLines: 0,
+		},
+		{
+			name:      "Always logs client errors when sampling",
+			options:   middlewares.RequestLoggerOptions{SampleRate: 0.0000001},
+			path:      "/widgets",
+			status:    http.StatusNotFound,
+			wantLines: 1,
+		},
+		{
+			name:      "Logs slow requests at the warning level",
+			options:   middlewares.RequestLoggerOptions{SampleRate: 0.0000001, SlowThreshold: time.Millisecond},
+			path:      "/widgets",
+			status:    http.StatusOK,
+			delay:     5 * time.Millisecond,
+			wantLines: 1,
+			want:      "level=warning",
+		},
+		{
+			name:      "Logs fast requests at the info level",
+			options:   middlewares.RequestLoggerOptions{SlowThreshold: time.Minute},
+			path:      "/widgets",
+			status:    http.StatusOK,
+			wantLines: 1,
+			want:      "level=info",
+		},
+		{
+			name:      "Redacts sensitive query parameters by default",
+			options:   middlewares.RequestLoggerOptions{},
+			path:      "/widgets?color=blue&token=abc&API_KEY=def&password",
+			status:    http.StatusOK,
+			wantLines: 1,
+			want:      `queryParams="color=blue&token=REDACTED&API_KEY=REDACTED&password"`,
+		},
+		{
+			name:      "Redacts configured query parameters",
+			options:   middlewares.RequestLoggerOptions{RedactQueryParams: []string{"secret"}},
+			path:      "/widgets?secret=abc&token=def",
+			status:    http.StatusOK,
+			wantLines: 1,
+			want:      `queryParams="secret=REDACTED&token=def"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			logger := logrus.New()
+			logger.SetOutput(output)
+
+			handler := middlewares.RequestLoggerWith(middlewares.NewLogrusLogger(logrus.NewEntry(logger)), tt.options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.delay)
+				w.WriteHeader(tt.status)
+			}))
+
+			for i := 0; i < 20; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+				if tt.wantLines == 1 {
+					break
+				}
+			}
+
+			got := strings.Count(output.String(), "\n")
+
+			if got != tt.wantLines {
+				t.Errorf("wanted %d log lines, got %d: %s", tt.wantLines, got, output.String())
+			}
+
+			if tt.want != "" && !strings.Contains(output.String(), tt.want) {
+				t.Errorf("expected '%s' in log output: %s", tt.want, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+loggerHolder is shared by the request context and the response writer
+so fields added later in the request, such as the authenticated subject,
+are seen by both.
+*/
+type loggerHolder struct {
+	logger Logger
+}
+
+type loggerResponseWriter struct {
+	http.ResponseWriter
+	holder *loggerHolder
+}
+
+func (lw *loggerResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+type requestScopedLogger struct {
+	handler http.Handler
+	logger  Logger
+}
+
+func (m *requestScopedLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fields := LogFields{
+		"ip":     realIP(r),
+		"method": r.Method,
+	}
+
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			fields["route"] = template
+		}
+	}
+
+	holder := &loggerHolder{logger: WithRequestFields(m.logger, r).WithFields(fields)}
+	ctx := context.WithValue(r.Context(), loggerContextKey, holder)
+
+	r = r.WithContext(ctx)
+	m.handler.ServeHTTP(withOptionalInterfaces(&loggerResponseWriter{ResponseWriter: w, holder: holder}), r)
+}
+
+/*
+RequestScopedLogger returns a middleware that attaches a logger to each
+request. The logger is derived from logger and includes the request ID,
+IP address, method, and route template. VerifyJWT adds the authenticated
+subject. Use LoggerFromContext (or nerdweb.LoggerFromRequest) to get it.
+Install this middleware after RequestID.
+
+Example:
+
+  mux.Use(middlewares.RequestID(middlewares.RequestIDOptions{}))
+  mux.Use(middlewares.RequestScopedLogger(logger))
+*/
+func RequestScopedLogger(logger Logger) mux.MiddlewareFunc {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := &requestScopedLogger{
+				handler: next,
+				logger:  logger,
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+/*
+LoggerFromContext returns the logger attached by RequestScopedLogger.
+The second return value is false if there is no logger.
+*/
+func LoggerFromContext(r *http.Request) (Logger, bool) {
+	if holder, ok := r.Context().Value(loggerContextKey).(*loggerHolder); ok {
+		return holder.logger, true
+	}
+
+	return nil, false
+}
+
+/*
+LoggerFromResponseWriter returns the logger attached by RequestScopedLogger,
+found through the response writer. This lets functions that only have the
+response writer, such as nerdweb.WriteJSON, log with request details.
+*/
+func LoggerFromResponseWriter(w http.ResponseWriter) (Logger, bool) {
+	for w != nil {
+		if lw, ok := w.(*loggerResponseWriter); ok {
+			return lw.holder.logger, true
+		}
+
+		if ww, ok := w.(interface{ wrapped() http.ResponseWriter }); ok {
+			w = ww.wrapped()
+			continue
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+
+		if !ok {
+			break
+		}
+
+		w = unwrapper.Unwrap()
+	}
+
+	return nil, false
+}
+
+func addLoggerFields(r *http.Request, fields LogFields) {
+	if holder, ok := r.Context().Value(loggerContextKey).(*loggerHolder); ok {
+		holder.logger = holder.logger.WithFields(fields)
+	}
+}
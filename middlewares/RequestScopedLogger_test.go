@@ -0,0 +1,49 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestScopedLogger(t *testing.T) {
+	secret := []byte("super-secret")
+	output := &bytes.Buffer{}
+	base := logrus.New()
+	base.SetOutput(output)
+
+	logger := middlewares.NewLogrusLogger(logrus.NewEntry(base))
+	config := middlewares.JWTConfig{Key: secret}
+	token := signTestJWT(t, "HS256", "", secret, map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+
+	var gotContextLogger, gotWriterLogger middlewares.Logger
+
+	handler := middlewares.RequestScopedLogger(logger)(middlewares.VerifyJWT(func(w http.ResponseWriter, r *http.Request) {
+		gotContextLogger, _ = middlewares.LoggerFromContext(r)
+		gotWriterLogger, _ = middlewares.LoggerFromResponseWriter(w)
+	}, logger, config, func(logger middlewares.Logger, w http.ResponseWriter) {
+		t.Errorf("did not expect an invalid token")
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if gotContextLogger == nil || gotContextLogger != gotWriterLogger {
+		t.Fatalf("expected the same logger from the context and response writer")
+	}
+
+	gotContextLogger.Info("testing")
+
+	for _, want := range []string{"subject=user-1", "method=POST"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("expected '%s' in log output: %s", want, output.String())
+		}
+	}
+}
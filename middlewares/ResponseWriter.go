@@ -0,0 +1,290 @@
+package middlewares
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+/*
+ResponseRecorder wraps a response writer and records the status code,
+the number of body bytes written, whether the headers have been sent,
+and whether the connection was hijacked. Create one with
+WrapResponseWriter so the optional interfaces of the underlying writer
+are kept.
+*/
+type ResponseRecorder struct {
+	http.ResponseWriter
+	Bytes       int64
+	Hijacked    bool
+	Status      int
+	WroteHeader bool
+}
+
+/*
+WrapResponseWriter wraps w in a ResponseRecorder. The returned writer
+implements http.Flusher, http.Hijacker, http.Pusher, and io.ReaderFrom
+only when w does, and unwraps to w for http.ResponseController. Pass
+the returned writer to the next handler and read the results from the
+recorder.
+
+Example:
+
+  wrapped, recorder := middlewares.WrapResponseWriter(w)
+  next.ServeHTTP(wrapped, r)
+
+  fmt.Println(recorder.Status, recorder.Bytes)
+*/
+func WrapResponseWriter(w http.ResponseWriter) (http.ResponseWriter, *ResponseRecorder) {
+	recorder := &ResponseRecorder{
+		ResponseWriter: w,
+		Status:         http.StatusOK,
+	}
+
+	return withOptionalInterfaces(recorder), recorder
+}
+
+func (rr *ResponseRecorder) Write(b []byte) (int, error) {
+	rr.WroteHeader = true
+
+	n, err := rr.ResponseWriter.Write(b)
+	rr.Bytes += int64(n)
+
+	return n, err
+}
+
+func (rr *ResponseRecorder) WriteHeader(code int) {
+	if !rr.WroteHeader {
+		rr.Status = code
+		rr.WroteHeader = code < 100 || code > 199
+	}
+
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+/*
+Unwrap returns the underlying response writer. It is used by
+http.ResponseController.
+*/
+func (rr *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+func (rr *ResponseRecorder) headersSent() {
+	rr.WroteHeader = true
+}
+
+func (rr *ResponseRecorder) hijacked() {
+	rr.Hijacked = true
+}
+
+func (rr *ResponseRecorder) wroteBytes(n int64) {
+	rr.WroteHeader = true
+	rr.Bytes += n
+}
+
+/*
+responseWrapper is a response writer that wraps another one.
+*/
+type responseWrapper interface {
+	http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+/*
+wrappedWriter lets code that looks for a specific wrapper type, such
+as LoggerFromResponseWriter, find it behind the optional interfaces.
+*/
+type wrappedWriter struct {
+	responseWrapper
+}
+
+func (ww wrappedWriter) wrapped() http.ResponseWriter {
+	return ww.responseWrapper
+}
+
+/*
+responseEvents is implemented by wrappers that want to know when the
+optional interfaces are used, such as ResponseRecorder.
+*/
+type responseEvents interface {
+	headersSent()
+	hijacked()
+	wroteBytes(n int64)
+}
+
+type nopResponseEvents struct{}
+
+func (nopResponseEvents) headersSent()       {}
+func (nopResponseEvents) hijacked()          {}
+func (nopResponseEvents) wroteBytes(n int64) {}
+
+type flusher struct {
+	events responseEvents
+	w      http.ResponseWriter
+}
+
+func (f flusher) Flush() {
+	f.events.headersSent()
+	f.w.(http.Flusher).Flush()
+}
+
+type hijacker struct {
+	events responseEvents
+	w      http.ResponseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.w.(http.Hijacker).Hijack()
+
+	if err == nil {
+		h.events.hijacked()
+	}
+
+	return conn, rw, err
+}
+
+type pusher struct {
+	w http.ResponseWriter
+}
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.(http.Pusher).Push(target, opts)
+}
+
+type readerFrom struct {
+	events responseEvents
+	w      http.ResponseWriter
+}
+
+func (rf readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	rf.events.headersSent()
+
+	n, err := rf.w.(io.ReaderFrom).ReadFrom(src)
+	rf.events.wroteBytes(n)
+
+	return n, err
+}
+
+/*
+withOptionalInterfaces returns w extended with exactly the optional
+interfaces (http.Flusher, http.Hijacker, http.Pusher, and io.ReaderFrom)
+implemented by the writer it wraps. Calls through those interfaces are
+reported to w when it implements responseEvents.
+*/
+func withOptionalInterfaces(w responseWrapper) http.ResponseWriter {
+	underlying := w.Unwrap()
+	events, ok := w.(responseEvents)
+
+	if !ok {
+		events = nopResponseEvents{}
+	}
+
+	f := flusher{events: events, w: underlying}
+	h := hijacker{events: events, w: underlying}
+	p := pusher{w: underlying}
+	rf := readerFrom{events: events, w: underlying}
+
+	_, canFlush := underlying.(http.Flusher)
+	_, canHijack := underlying.(http.Hijacker)
+	_, canPush := underlying.(http.Pusher)
+	_, canReadFrom := underlying.(io.ReaderFrom)
+
+	switch {
+	case canFlush && canHijack && canPush && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{wrappedWriter{w}, f, h, p, rf}
+	case canFlush && canHijack && canPush:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{wrappedWriter{w}, f, h, p}
+	case canFlush && canHijack && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{wrappedWriter{w}, f, h, rf}
+	case canFlush && canPush && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{wrappedWriter{w}, f, p, rf}
+	case canHijack && canPush && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{wrappedWriter{w}, h, p, rf}
+	case canFlush && canHijack:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			http.Hijacker
+		}{wrappedWriter{w}, f, h}
+	case canFlush && canPush:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			http.Pusher
+		}{wrappedWriter{w}, f, p}
+	case canFlush && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Flusher
+			io.ReaderFrom
+		}{wrappedWriter{w}, f, rf}
+	case canHijack && canPush:
+		return struct {
+			wrappedWriter
+			http.Hijacker
+			http.Pusher
+		}{wrappedWriter{w}, h, p}
+	case canHijack && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{wrappedWriter{w}, h, rf}
+	case canPush && canReadFrom:
+		return struct {
+			wrappedWriter
+			http.Pusher
+			io.ReaderFrom
+		}{wrappedWriter{w}, p, rf}
+	case canFlush:
+		return struct {
+			wrappedWriter
+			http.Flusher
+		}{wrappedWriter{w}, f}
+	case canHijack:
+		return struct {
+			wrappedWriter
+			http.Hijacker
+		}{wrappedWriter{w}, h}
+	case canPush:
+		return struct {
+			wrappedWriter
+			http.Pusher
+		}{wrappedWriter{w}, p}
+	case canReadFrom:
+		return struct {
+			wrappedWriter
+			io.ReaderFrom
+		}{wrappedWriter{w}, rf}
+	default:
+		return w
+	}
+}
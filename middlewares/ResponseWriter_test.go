@@ -0,0 +1,175 @@
+package middlewares_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+)
+
+type basicWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (w *basicWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+
+	return w.header
+}
+
+func (w *basicWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *basicWriter) WriteHeader(status int)      { w.status = status }
+
+type flushWriter struct {
+	basicWriter
+	flushed bool
+}
+
+func (w *flushWriter) Flush() { w.flushed = true }
+
+type hijackWriter struct {
+	basicWriter
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, nil, nil
+}
+
+type pushReadFromWriter struct {
+	basicWriter
+}
+
+func (w *pushReadFromWriter) Push(target string, opts *http.PushOptions) error { return nil }
+func (w *pushReadFromWriter) ReadFrom(src io.Reader) (int64, error)            { return w.body.ReadFrom(src) }
+
+func TestWrapResponseWriterInterfaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		w            http.ResponseWriter
+		wantFlusher  bool
+		wantHijacker bool
+		wantPusher   bool
+		wantReadFrom bool
+	}{
+		{name: "Plain writer", w: &basicWriter{}},
+		{name: "Flusher", w: &flushWriter{}, wantFlusher: true},
+		{name: "Hijacker", w: &hijackWriter{}, wantHijacker: true},
+		{name: "Pusher and ReaderFrom", w: &pushReadFromWriter{}, wantPusher: true, wantReadFrom: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped, _ := middlewares.WrapResponseWriter(tt.w)
+
+			_, gotFlusher := wrapped.(http.Flusher)
+			_, gotHijacker := wrapped.(http.Hijacker)
+			_, gotPusher := wrapped.(http.Pusher)
+			_, gotReadFrom := wrapped.(io.ReaderFrom)
+
+			if gotFlusher != tt.wantFlusher || gotHijacker != tt.wantHijacker || gotPusher != tt.wantPusher || gotReadFrom != tt.wantReadFrom {
+				t.Errorf("wanted flusher %v, hijacker %v, pusher %v, reader from %v, got %v, %v, %v, %v",
+					tt.wantFlusher, tt.wantHijacker, tt.wantPusher, tt.wantReadFrom,
+					gotFlusher, gotHijacker, gotPusher, gotReadFrom)
+			}
+
+			if unwrapper, ok := wrapped.(interface{ Unwrap() http.ResponseWriter }); !ok || unwrapper.Unwrap() != tt.w {
+				t.Errorf("expected the wrapped writer to unwrap to the original writer")
+			}
+		})
+	}
+}
+
+func TestWrapResponseWriterRecords(t *testing.T) {
+	t.Run("Records status and bytes written", func(t *testing.T) {
+		wrapped, recorder := middlewares.WrapResponseWriter(&basicWriter{})
+		wrapped.WriteHeader(http.StatusContinue)
+		wrapped.WriteHeader(http.StatusAccepted)
+		_, _ = wrapped.Write([]byte("hello"))
+
+		if recorder.Status != http.StatusAccepted || recorder.Bytes != 5 || !recorder.WroteHeader {
+			t.Errorf("wanted status 202, 5 bytes, and headers sent, got %d, %d, %v", recorder.Status, recorder.Bytes, recorder.WroteHeader)
+		}
+	})
+
+	t.Run("Flush sends the headers", func(t *testing.T) {
+		underlying := &flushWriter{}
+		wrapped, recorder := middlewares.WrapResponseWriter(underlying)
+		wrapped.(http.Flusher).Flush()
+
+		if !underlying.flushed || !recorder.WroteHeader {
+			t.Errorf("expected the flush to reach the writer and mark headers as sent")
+		}
+	})
+
+	t.Run("ReadFrom counts bytes", func(t *testing.T) {
+		wrapped, recorder := middlewares.WrapResponseWriter(&pushReadFromWriter{})
+		_, _ = wrapped.(io.ReaderFrom).ReadFrom(strings.NewReader("streamed"))
+
+		if recorder.Bytes != 8 || !recorder.WroteHeader {
+			t.Errorf("wanted 8 bytes and headers sent, got %d, %v", recorder.Bytes, recorder.WroteHeader)
+		}
+	})
+
+	t.Run("Hijack is recorded", func(t *testing.T) {
+		wrapped, recorder := middlewares.WrapResponseWriter(&hijackWriter{})
+		conn, _, _ := wrapped.(http.Hijacker).Hijack()
+		_ = conn.Close()
+
+		if !recorder.Hijacked {
+			t.Errorf("expected the hijack to be recorded")
+		}
+	})
+}
+
+func TestMiddlewaresPreserveStreaming(t *testing.T) {
+	logger := middlewares.NewNopLogger()
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Errorf("expected the writer to implement http.Hijacker")
+		}
+
+		if _, ok := middlewares.LoggerFromResponseWriter(w); !ok {
+			t.Errorf("expected the request-scoped logger to be found through the writer")
+		}
+
+		controller := http.NewResponseController(w)
+		_, _ = w.Write([]byte("data: first\n\n"))
+
+		if err := controller.Flush(); err != nil {
+			t.Errorf("expected flush to succeed, got %v", err)
+		}
+	})
+
+	handler = middlewares.Recover(logger, middlewares.RecoverOptions{})(handler)
+	handler = middlewares.RequestScopedLogger(logger)(handler)
+	handler = middlewares.RequestLoggerWith(logger, middlewares.RequestLoggerOptions{})(handler)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+
+	if string(body) != "data: first\n\n" {
+		t.Errorf("wanted streamed body, got %q", string(body))
+	}
+}
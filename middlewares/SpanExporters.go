@@ -0,0 +1,429 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrExporterShutdown = errors.New("span exporter is shut down")
+)
+
+type jsonSpan struct {
+	Attributes   map[string]interface{} `json:"attributes"`
+	Duration     string                 `json:"duration"`
+	EndTime      time.Time              `json:"endTime"`
+	Name         string                 `json:"name"`
+	ParentSpanID string                 `json:"parentSpanID,omitempty"`
+	ServiceName  string                 `json:"serviceName,omitempty"`
+	SpanID       string                 `json:"spanID"`
+	StartTime    time.Time              `json:"startTime"`
+	Status       int                    `json:"status"`
+	TraceID      string                 `json:"traceID"`
+	TraceState   string                 `json:"traceState,omitempty"`
+}
+
+/*
+JSONSpanExporter writes each span as one line of JSON. It is useful
+for development and for log-based trace collection.
+*/
+type JSONSpanExporter struct {
+	lock   sync.Mutex
+	output io.Writer
+}
+
+/*
+NewJSONSpanExporter creates an exporter that writes spans to output.
+When output is nil spans are written to os.Stdout.
+*/
+func NewJSONSpanExporter(output io.Writer) *JSONSpanExporter {
+	if output == nil {
+		output = os.Stdout
+	}
+
+	return &JSONSpanExporter{output: output}
+}
+
+/*
+ExportSpans writes the spans.
+*/
+func (e *JSONSpanExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	b := &bytes.Buffer{}
+	encoder := json.NewEncoder(b)
+
+	for _, span := range spans {
+		line := jsonSpan{
+			Attributes:  span.Attributes,
+			Duration:    span.EndTime.Sub(span.StartTime).String(),
+			EndTime:     span.EndTime,
+			Name:        span.Name,
+			ServiceName: span.ServiceName,
+			SpanID:      span.SpanContext.SpanID.String(),
+			StartTime:   span.StartTime,
+			Status:      span.Status,
+			TraceID:     span.SpanContext.TraceID.String(),
+			TraceState:  span.SpanContext.TraceState,
+		}
+
+		if span.ParentSpanID.IsValid() {
+			line.ParentSpanID = span.ParentSpanID.String()
+		}
+
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("error encoding span: %w", err)
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	_, err := e.output.Write(b.Bytes())
+	return err
+}
+
+/*
+OTLPExporterConfig configures an OTLPExporter.
+
+Endpoint is the base URL of the collector, for example
+"http://localhost:4318". Spans are posted to Endpoint + "/v1/traces".
+Headers are added to every export request, for example for
+authentication. Spans are sent when BatchSize spans are waiting
+(default 512) or every FlushInterval (default 5 seconds).
+*/
+type OTLPExporterConfig struct {
+	BatchSize     int
+	Endpoint      string
+	FlushInterval time.Duration
+	Headers       map[string]string
+	HTTPClient    *http.Client
+	Logger        Logger
+}
+
+/*
+OTLPExporter sends spans to an OpenTelemetry collector using OTLP over
+HTTP with JSON encoding. Spans are buffered and sent in batches in the
+background. Call Shutdown when the server stops to send the remaining
+spans.
+*/
+type OTLPExporter struct {
+	config       OTLPExporterConfig
+	done         chan struct{}
+	flush        chan struct{}
+	lock         sync.Mutex
+	pending      []Span
+	shutdown     bool
+	shutdownOnce sync.Once
+	stopped      chan struct{}
+}
+
+/*
+NewOTLPExporter creates an OTLP/HTTP exporter and starts sending spans
+in the background.
+
+Example:
+
+  exporter := middlewares.NewOTLPExporter(middlewares.OTLPExporterConfig{
+    Endpoint: "http://localhost:4318",
+  })
+
+  nerdweb.Run(ctx, server, nerdweb.RunOptions{
+    OnShutdown: []nerdweb.Hook{exporter.Shutdown},
+  })
+*/
+func NewOTLPExporter(config OTLPExporterConfig) *OTLPExporter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.Logger == nil {
+		config.Logger = defaultLogger()
+	}
+
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	result := &OTLPExporter{
+		config:  config,
+		done:    make(chan struct{}),
+		flush:   make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+
+	go result.run()
+	return result
+}
+
+/*
+ExportSpans queues spans to be sent to the collector. After Shutdown
+the spans are dropped and ErrExporterShutdown is returned.
+*/
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	e.lock.Lock()
+
+	if e.shutdown {
+		e.lock.Unlock()
+		return ErrExporterShutdown
+	}
+
+	e.pending = append(e.pending, spans...)
+	full := len(e.pending) >= e.config.BatchSize
+	e.lock.Unlock()
+
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+/*
+Flush sends all queued spans to the collector now.
+*/
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	e.lock.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.lock.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	return e.send(ctx, spans)
+}
+
+/*
+Shutdown stops the background sender and sends the queued spans. Spans
+exported afterwards are dropped. It is safe to call more than once, and
+matches the nerdweb.Hook signature so it can be used as a shutdown hook.
+*/
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.shutdownOnce.Do(func() {
+		e.lock.Lock()
+		e.shutdown = true
+		e.lock.Unlock()
+
+		close(e.done)
+	})
+
+	<-e.stopped
+	return e.Flush(ctx)
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.flush:
+		}
+
+		if err := e.Flush(context.Background()); err != nil {
+			e.config.Logger.WithError(err).Error("error sending spans to the OTLP collector")
+		}
+	}
+}
+
+func (e *OTLPExporter) send(ctx context.Context, spans []Span) error {
+	var (
+		err      error
+		b        []byte
+		request  *http.Request
+		response *http.Response
+	)
+
+	if b, err = json.Marshal(newOTLPTraces(spans)); err != nil {
+		return fmt.Errorf("error marshaling spans: %w", err)
+	}
+
+	if request, err = http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint+"/v1/traces", bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("error creating OTLP request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	for key, value := range e.config.Headers {
+		request.Header.Set(key, value)
+	}
+
+	if response, err = e.config.HTTPClient.Do(request); err != nil {
+		return fmt.Errorf("error sending spans: %w", err)
+	}
+
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("OTLP collector returned status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+/*
+The types below are the OTLP/JSON encoding of an
+ExportTraceServiceRequest. Trace and span IDs are hex, and 64 bit
+integers are strings.
+*/
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Kind              int            `json:"kind"`
+	Name              string         `json:"name"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	SpanID            string         `json:"spanId"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	Status            otlpStatus     `json:"status"`
+	TraceID           string         `json:"traceId"`
+	TraceState        string         `json:"traceState,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+const (
+	otlpSpanKindServer  = 2
+	otlpStatusCodeUnset = 0
+	otlpStatusCodeError = 2
+)
+
+func newOTLPTraces(spans []Span) otlpTraces {
+	byService := map[string][]otlpSpan{}
+	services := []string{}
+
+	for _, span := range spans {
+		if _, ok := byService[span.ServiceName]; !ok {
+			services = append(services, span.ServiceName)
+		}
+
+		converted := otlpSpan{
+			Attributes:        newOTLPAttributes(span.Attributes),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Kind:              otlpSpanKindServer,
+			Name:              span.Name,
+			SpanID:            span.SpanContext.SpanID.String(),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusCodeUnset},
+			TraceID:           span.SpanContext.TraceID.String(),
+			TraceState:        span.SpanContext.TraceState,
+		}
+
+		if span.ParentSpanID.IsValid() {
+			converted.ParentSpanID = span.ParentSpanID.String()
+		}
+
+		if span.Status >= 500 {
+			converted.Status.Code = otlpStatusCodeError
+		}
+
+		byService[span.ServiceName] = append(byService[span.ServiceName], converted)
+	}
+
+	result := otlpTraces{ResourceSpans: make([]otlpResourceSpans, 0, len(services))}
+
+	for _, service := range services {
+		resource := otlpResource{Attributes: []otlpKeyValue{}}
+
+		if service != "" {
+			resource.Attributes = newOTLPAttributes(map[string]interface{}{"service.name": service})
+		}
+
+		result.ResourceSpans = append(result.ResourceSpans, otlpResourceSpans{
+			Resource: resource,
+			ScopeSpans: []otlpScopeSpans{
+				{Scope: otlpScope{Name: "github.com/app-nerds/nerdweb/v2"}, Spans: byService[service]},
+			},
+		})
+	}
+
+	return result
+}
+
+func newOTLPAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	result := make([]otlpKeyValue, 0, len(keys))
+
+	for _, key := range keys {
+		var value map[string]interface{}
+
+		switch v := attributes[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+		}
+
+		result = append(result, otlpKeyValue{Key: key, Value: value})
+	}
+
+	return result
+}
@@ -0,0 +1,362 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	TraceparentHeader string = "traceparent"
+	TracestateHeader  string = "tracestate"
+	maxTracestateSize int    = 512
+)
+
+var (
+	ErrTraceparentInvalid = errors.New("invalid traceparent")
+)
+
+/*
+TraceID identifies a trace. It is shared by every span in the trace.
+*/
+type TraceID [16]byte
+
+/*
+String returns the trace ID as lowercase hex.
+*/
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+/*
+IsValid returns false for the all zero trace ID.
+*/
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+/*
+SpanID identifies a span within a trace.
+*/
+type SpanID [8]byte
+
+/*
+String returns the span ID as lowercase hex.
+*/
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+/*
+IsValid returns false for the all zero span ID.
+*/
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+/*
+SpanContext is the part of a span that is propagated between services
+in the W3C traceparent and tracestate headers.
+*/
+type SpanContext struct {
+	SpanID     SpanID
+	TraceFlags byte
+	TraceID    TraceID
+	TraceState string
+}
+
+/*
+IsSampled returns true when the sampled trace flag is set.
+*/
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&0x01 == 0x01
+}
+
+/*
+Traceparent returns the span context as a version 00 traceparent header
+value.
+*/
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.TraceFlags})
+}
+
+/*
+Inject sets the traceparent and tracestate headers of an outgoing
+request so the next service continues the trace.
+
+Example:
+
+  spanContext, _ := middlewares.SpanContextFromContext(r)
+
+  outgoing, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, "https://inventory/widgets", nil)
+  spanContext.Inject(outgoing.Header)
+*/
+func (sc SpanContext) Inject(header http.Header) {
+	header.Set(TraceparentHeader, sc.Traceparent())
+
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+/*
+ParseTraceparent parses a W3C traceparent header value. Values with a
+future version are accepted as long as they start with a valid version
+00 value.
+*/
+func ParseTraceparent(value string) (SpanContext, error) {
+	result := SpanContext{}
+
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return result, ErrTraceparentInvalid
+	}
+
+	version, ok := decodeLowerHex(value[0:2])
+
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return result, ErrTraceparentInvalid
+	}
+
+	traceID, ok := decodeLowerHex(value[3:35])
+
+	if !ok {
+		return result, ErrTraceparentInvalid
+	}
+
+	spanID, ok := decodeLowerHex(value[36:52])
+
+	if !ok {
+		return result, ErrTraceparentInvalid
+	}
+
+	flags, ok := decodeLowerHex(value[53:55])
+
+	if !ok {
+		return result, ErrTraceparentInvalid
+	}
+
+	copy(result.TraceID[:], traceID)
+	copy(result.SpanID[:], spanID)
+	result.TraceFlags = flags[0]
+
+	if !result.TraceID.IsValid() || !result.SpanID.IsValid() {
+		return SpanContext{}, ErrTraceparentInvalid
+	}
+
+	return result, nil
+}
+
+func decodeLowerHex(value string) ([]byte, bool) {
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return nil, false
+		}
+	}
+
+	result, err := hex.DecodeString(value)
+	return result, err == nil
+}
+
+/*
+Span is a finished server span recorded by the Tracing middleware.
+*/
+type Span struct {
+	Attributes   map[string]interface{}
+	EndTime      time.Time
+	Name         string
+	ParentSpanID SpanID
+	ServiceName  string
+	SpanContext  SpanContext
+	StartTime    time.Time
+	Status       int
+}
+
+/*
+SpanExporter sends finished spans somewhere. ExportSpans is called once
+for every sampled request, after the response has been written, so
+exporters that make network calls should buffer spans and send them in
+the background.
+*/
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []Span) error
+}
+
+/*
+TracingOptions configures the Tracing middleware.
+
+Exporter receives the finished spans; when it is nil spans are not
+exported, but trace context is still propagated. ServiceName is
+recorded on every span. Logger is used to log export errors and
+defaults to the logrus standard logger.
+*/
+type TracingOptions struct {
+	Exporter    SpanExporter
+	Logger      Logger
+	ServiceName string
+}
+
+type tracer struct {
+	handler http.Handler
+	options TracingOptions
+}
+
+func (m *tracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	spanContext := SpanContext{TraceFlags: 0x01}
+	parentSpanID := SpanID{}
+
+	if parent, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+		spanContext.TraceID = parent.TraceID
+		spanContext.TraceFlags = parent.TraceFlags
+		spanContext.TraceState = parseTracestate(r.Header.Values(TracestateHeader))
+		parentSpanID = parent.SpanID
+	} else {
+		_, _ = rand.Read(spanContext.TraceID[:])
+	}
+
+	_, _ = rand.Read(spanContext.SpanID[:])
+
+	addLoggerFields(r, LogFields{
+		"spanID":  spanContext.SpanID.String(),
+		"traceID": spanContext.TraceID.String(),
+	})
+
+	ctx := context.WithValue(r.Context(), spanContextKey, spanContext)
+	r = r.WithContext(ctx)
+
+	wrapped, recorder := WrapResponseWriter(w)
+	startTime := time.Now()
+
+	defer func() {
+		if m.options.Exporter == nil || !spanContext.IsSampled() {
+			return
+		}
+
+		span := m.newSpan(r, recorder, spanContext, parentSpanID, startTime)
+
+		if err := m.options.Exporter.ExportSpans(context.Background(), []Span{span}); err != nil {
+			WithRequestFields(m.options.Logger, r).WithError(err).Error("error exporting span")
+		}
+	}()
+
+	m.handler.ServeHTTP(wrapped, r)
+}
+
+func (m *tracer) newSpan(r *http.Request, recorder *ResponseRecorder, spanContext SpanContext, parentSpanID SpanID, startTime time.Time) Span {
+	route := ""
+
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+
+	attributes := map[string]interface{}{
+		"client.address":            realIP(r),
+		"http.request.method":       r.Method,
+		"http.response.body.size":   recorder.Bytes,
+		"http.response.status_code": recorder.Status,
+		"url.path":                  r.URL.Path,
+	}
+
+	name := r.Method
+
+	if route != "" {
+		attributes["http.route"] = route
+		name += " " + route
+	}
+
+	if userAgent := r.UserAgent(); userAgent != "" {
+		attributes["user_agent.original"] = userAgent
+	}
+
+	return Span{
+		Attributes:   attributes,
+		EndTime:      time.Now(),
+		Name:         name,
+		ParentSpanID: parentSpanID,
+		ServiceName:  m.options.ServiceName,
+		SpanContext:  spanContext,
+		StartTime:    startTime,
+		Status:       recorder.Status,
+	}
+}
+
+/*
+parseTracestate joins the tracestate header values into one list. The
+list is dropped when it is larger than the size vendors are required
+to propagate.
+*/
+func parseTracestate(values []string) string {
+	members := make([]string, 0, len(values))
+
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				members = append(members, member)
+			}
+		}
+	}
+
+	result := strings.Join(members, ",")
+
+	if len(result) > maxTracestateSize {
+		return ""
+	}
+
+	return result
+}
+
+/*
+Tracing returns a middleware that takes part in W3C Trace Context
+distributed tracing. The incoming traceparent and tracestate headers
+are parsed, or a new trace is started, and a span context for this
+server's span is stored in the request context. Use
+SpanContextFromContext to read it and SpanContext.Inject to propagate
+it to outgoing requests. The trace and span IDs are added to the
+request-scoped logger. When the request finishes a span with the
+route, status, and timing is sent to the exporter.
+
+Example:
+
+  exporter := middlewares.NewOTLPExporter(middlewares.OTLPExporterConfig{
+    Endpoint: "http://localhost:4318",
+  })
+
+  mux.Use(middlewares.Tracing(middlewares.TracingOptions{
+    Exporter:    exporter,
+    ServiceName: "widgets",
+  }))
+*/
+func Tracing(options TracingOptions) mux.MiddlewareFunc {
+	if options.Logger == nil {
+		options.Logger = defaultLogger()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := &tracer{
+				handler: next,
+				options: options,
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+/*
+SpanContextFromContext returns the span context stored by Tracing. The
+second return value is false if there is no span context.
+*/
+func SpanContextFromContext(r *http.Request) (SpanContext, bool) {
+	spanContext, ok := r.Context().Value(spanContextKey).(SpanContext)
+	return spanContext, ok
+}
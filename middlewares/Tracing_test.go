@@ -0,0 +1,422 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app-nerds/nerdweb/v2/middlewares"
+	"github.com/gorilla/mux"
+)
+
+type recordingExporter struct {
+	lock  sync.Mutex
+	spans []middlewares.Span
+}
+
+func (e *recordingExporter) ExportSpans(ctx context.Context, spans []middlewares.Span) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantErr     error
+		wantTraceID string
+		wantSpanID  string
+		wantSampled bool
+	}{
+		{
+			name:        "Parses a sampled version 00 value",
+			value:       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			wantSampled: true,
+		},
+		{
+			name:        "Parses an unsampled value",
+			value:       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			wantSampled: false,
+		},
+		{
+			name:        "Accepts future versions with extra fields",
+			value:       "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			wantSampled: true,
+		},
+		{
+			name:    "Rejects extra fields in version 00",
+			value:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			wantErr: middlewares.ErrTraceparentInvalid,
+		},
+		{
+			name:    "Rejects version ff",
+			value:   "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantErr: middlewares.ErrTraceparentInvalid,
+		},
+		{
+			name:    "Rejects uppercase hex",
+			value:   "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			wantErr: middlewares.ErrTraceparentInvalid,
+		},
+		{
+			name:    "Rejects an all zero trace ID",
+			value:   "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			wantErr: middlewares.ErrTraceparentInvalid,
+		},
+		{
+			name:    "Rejects an all zero span ID",
+			value:   "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			wantErr: middlewares.ErrTraceparentInvalid,
+		},
+		{
+			name:    "Rejects an empty value",
+			value:   "",
+			wantErr: middlewares.ErrTraceparentInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := middlewares.ParseTraceparent(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("wanted error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.TraceID.String() != tt.wantTraceID || got.SpanID.String() != tt.wantSpanID || got.IsSampled() != tt.wantSampled {
+				t.Errorf("wanted %s %s sampled %v, got %s %s sampled %v",
+					tt.wantTraceID, tt.wantSpanID, tt.wantSampled,
+					got.TraceID, got.SpanID, got.IsSampled())
+			}
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		tracestate  []string
+		wantExport  bool
+		wantTraceID string
+		wantParent  string
+		wantState   string
+	}{
+		{
+			name:        "Continues an incoming trace",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			tracestate:  []string{"congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7"},
+			wantExport:  true,
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParent:  "00f067aa0ba902b7",
+			wantState:   "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7",
+		},
+		{
+			name:       "Starts a new trace without a traceparent",
+			wantExport: true,
+		},
+		{
+			name:        "Starts a new trace for an invalid traceparent",
+			traceparent: "00-xyz",
+			wantExport:  true,
+		},
+		{
+			name:        "Does not export unsampled requests",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			wantExport:  false,
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &recordingExporter{}
+			var gotContext middlewares.SpanContext
+
+			router := mux.NewRouter()
+			router.Use(middlewares.Tracing(middlewares.TracingOptions{Exporter: exporter, ServiceName: "widgets"}))
+
+			router.HandleFunc("/widgets/{id}", func(w http.ResponseWriter, r *http.Request) {
+				gotContext, _ = middlewares.SpanContextFromContext(r)
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/widgets/4", nil)
+
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+
+			for _, value := range tt.tracestate {
+				r.Header.Add("tracestate", value)
+			}
+
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !gotContext.TraceID.IsValid() || !gotContext.SpanID.IsValid() {
+				t.Fatalf("expected a valid span context, got %+v", gotContext)
+			}
+
+			if tt.wantTraceID != "" && gotContext.TraceID.String() != tt.wantTraceID {
+				t.Errorf("wanted trace ID %s, got %s", tt.wantTraceID, gotContext.TraceID)
+			}
+
+			if gotContext.TraceState != tt.wantState {
+				t.Errorf("wanted trace state '%s', got '%s'", tt.wantState, gotContext.TraceState)
+			}
+
+			if !tt.wantExport {
+				if len(exporter.spans) != 0 {
+					t.Errorf("wanted no spans, got %d", len(exporter.spans))
+				}
+
+				return
+			}
+
+			if len(exporter.spans) != 1 {
+				t.Fatalf("wanted 1 span, got %d", len(exporter.spans))
+			}
+
+			span := exporter.spans[0]
+
+			if span.SpanContext != gotContext {
+				t.Errorf("wanted the exported span to match the request's span context")
+			}
+
+			if got := span.ParentSpanID; (tt.wantParent == "" && got.IsValid()) || (tt.wantParent != "" && got.String() != tt.wantParent) {
+				t.Errorf("wanted parent span ID '%s', got %s", tt.wantParent, got)
+			}
+
+			if span.Name != "GET /widgets/{id}" || span.Status != http.StatusTeapot || span.ServiceName != "widgets" {
+				t.Errorf("unexpected span name %s, status %d, service %s", span.Name, span.Status, span.ServiceName)
+			}
+
+			if span.Attributes["http.route"] != "/widgets/{id}" || span.EndTime.Before(span.StartTime) {
+				t.Errorf("unexpected span attributes %v or timing %s to %s", span.Attributes, span.StartTime, span.EndTime)
+			}
+		})
+	}
+}
+
+func TestSpanContextInject(t *testing.T) {
+	spanContext, _ := middlewares.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	spanContext.TraceState = "rojo=00f067aa0ba902b7"
+
+	header := http.Header{}
+	spanContext.Inject(header)
+
+	if got := header.Get("traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("wanted the traceparent to round trip, got %s", got)
+	}
+
+	if got := header.Get("tracestate"); got != "rojo=00f067aa0ba902b7" {
+		t.Errorf("wanted tracestate rojo=00f067aa0ba902b7, got %s", got)
+	}
+}
+
+func newTestSpan() middlewares.Span {
+	spanContext, _ := middlewares.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent, _ := middlewares.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01")
+	start := time.Unix(1700000000, 0)
+
+	return middlewares.Span{
+		Attributes:   map[string]interface{}{"http.route": "/widgets/{id}", "http.response.status_code": 500},
+		EndTime:      start.Add(250 * time.Millisecond),
+		Name:         "GET /widgets/{id}",
+		ParentSpanID: parent.SpanID,
+		ServiceName:  "widgets",
+		SpanContext:  spanContext,
+		StartTime:    start,
+		Status:       http.StatusInternalServerError,
+	}
+}
+
+func TestJSONSpanExporter(t *testing.T) {
+	output := &bytes.Buffer{}
+	exporter := middlewares.NewJSONSpanExporter(output)
+
+	if err := exporter.ExportSpans(context.Background(), []middlewares.Span{newTestSpan()}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	got := map[string]interface{}{}
+
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", output.String(), err)
+	}
+
+	wants := map[string]interface{}{
+		"traceID":      "4bf92f3577b34da6a3ce929d0e0e4736",
+		"spanID":       "00f067aa0ba902b7",
+		"parentSpanID": "b7ad6b7169203331",
+		"name":         "GET /widgets/{id}",
+		"duration":     "250ms",
+		"status":       float64(500),
+	}
+
+	for key, want := range wants {
+		if got[key] != want {
+			t.Errorf("wanted %s %v, got %v", key, want, got[key])
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		gotBody    []byte
+		gotPath    string
+		gotHeaders http.Header
+	)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		gotPath = r.URL.Path
+		gotHeaders = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	exporter := middlewares.NewOTLPExporter(middlewares.OTLPExporterConfig{
+		Endpoint:      collector.URL + "/",
+		FlushInterval: time.Hour,
+		Headers:       map[string]string{"Authorization": "Bearer collector-token"},
+	})
+
+	_ = exporter.ExportSpans(context.Background(), []middlewares.Span{newTestSpan()})
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if gotPath != "/v1/traces" || gotHeaders.Get("Content-Type") != "application/json" || gotHeaders.Get("Authorization") != "Bearer collector-token" {
+		t.Errorf("unexpected request to %s with headers %v", gotPath, gotHeaders)
+	}
+
+	request := struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string                 `json:"key"`
+					Value map[string]interface{} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []struct {
+					Attributes []struct {
+						Key   string                 `json:"key"`
+						Value map[string]interface{} `json:"value"`
+					} `json:"attributes"`
+					EndTimeUnixNano   string `json:"endTimeUnixNano"`
+					Kind              int    `json:"kind"`
+					Name              string `json:"name"`
+					ParentSpanID      string `json:"parentSpanId"`
+					SpanID            string `json:"spanId"`
+					StartTimeUnixNano string `json:"startTimeUnixNano"`
+					Status            struct {
+						Code int `json:"code"`
+					} `json:"status"`
+					TraceID string `json:"traceId"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}{}
+
+	if err := json.Unmarshal(gotBody, &request); err != nil {
+		t.Fatalf("expected OTLP JSON, got %s: %v", string(gotBody), err)
+	}
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("wanted one span, got %s", string(gotBody))
+	}
+
+	resource := request.ResourceSpans[0].Resource
+
+	if len(resource.Attributes) != 1 || resource.Attributes[0].Key != "service.name" || resource.Attributes[0].Value["stringValue"] != "widgets" {
+		t.Errorf("wanted service.name widgets, got %+v", resource.Attributes)
+	}
+
+	span := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanID != "00f067aa0ba902b7" || span.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("unexpected IDs %s %s %s", span.TraceID, span.SpanID, span.ParentSpanID)
+	}
+
+	if span.Kind != 2 || span.Status.Code != 2 || span.StartTimeUnixNano != "1700000000000000000" || span.EndTimeUnixNano != "1700000000250000000" {
+		t.Errorf("unexpected kind %d, status %d, or timing %s to %s", span.Kind, span.Status.Code, span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+
+	if len(span.Attributes) != 2 || span.Attributes[0].Key != "http.response.status_code" || span.Attributes[0].Value["intValue"] != "500" {
+		t.Errorf("unexpected attributes %+v", span.Attributes)
+	}
+}
+
+func TestOTLPExporterShutdown(t *testing.T) {
+	var requests int32
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer collector.Close()
+
+	exporter := middlewares.NewOTLPExporter(middlewares.OTLPExporterConfig{
+		Endpoint:      collector.URL,
+		FlushInterval: time.Hour,
+	})
+
+	_ = exporter.ExportSpans(context.Background(), []middlewares.Span{newTestSpan()})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := exporter.Shutdown(context.Background()); err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("wanted the queued span to be sent once, got %d requests", got)
+	}
+
+	if err := exporter.ExportSpans(context.Background(), []middlewares.Span{newTestSpan()}); !errors.Is(err, middlewares.ErrExporterShutdown) {
+		t.Errorf("wanted ErrExporterShutdown, got %v", err)
+	}
+
+	if err := exporter.Flush(context.Background()); err != nil || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("wanted spans exported after shutdown to be dropped, got %d requests", atomic.LoadInt32(&requests))
+	}
+}